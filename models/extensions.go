@@ -0,0 +1,318 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Every structure keeps the members it doesn't model (vendor extensions,
+// "redacted", "arin_originas0_originautnums", ...) in its Extensions map, and
+// modelled members whose empty value ("port43": "", "ipAddresses": {},
+// "maxSigLife": 0) encoding/json would omit, so decoding and re-encoding a
+// response does not drop data.
+
+func (l *Links) UnmarshalJSON(data []byte) error {
+	type links Links
+	var err error
+	l.Extensions, l.omitted, err = decodeObject(data, (*links)(l))
+	return err
+}
+
+func (l Links) MarshalJSON() ([]byte, error) {
+	type links Links
+	return encodeObject(links(l), l.Extensions, l.omitted)
+}
+
+func (n *Notices) UnmarshalJSON(data []byte) error {
+	type notices Notices
+	var err error
+	n.Extensions, n.omitted, err = decodeObject(data, (*notices)(n))
+	return err
+}
+
+func (n Notices) MarshalJSON() ([]byte, error) {
+	type notices Notices
+	return encodeObject(notices(n), n.Extensions, n.omitted)
+}
+
+func (r *Remarks) UnmarshalJSON(data []byte) error {
+	type remarks Remarks
+	var err error
+	r.Extensions, r.omitted, err = decodeObject(data, (*remarks)(r))
+	return err
+}
+
+func (r Remarks) MarshalJSON() ([]byte, error) {
+	type remarks Remarks
+	return encodeObject(remarks(r), r.Extensions, r.omitted)
+}
+
+func (e *Events) UnmarshalJSON(data []byte) error {
+	type events Events
+	var err error
+	e.Extensions, e.omitted, err = decodeObject(data, (*events)(e))
+	return err
+}
+
+func (e Events) MarshalJSON() ([]byte, error) {
+	type events Events
+	return encodeObject(events(e), e.Extensions, e.omitted)
+}
+
+func (p *PublicIds) UnmarshalJSON(data []byte) error {
+	type publicIds PublicIds
+	var err error
+	p.Extensions, p.omitted, err = decodeObject(data, (*publicIds)(p))
+	return err
+}
+
+func (p PublicIds) MarshalJSON() ([]byte, error) {
+	type publicIds PublicIds
+	return encodeObject(publicIds(p), p.Extensions, p.omitted)
+}
+
+func (a *IPAddresses) UnmarshalJSON(data []byte) error {
+	type ipAddresses IPAddresses
+	var err error
+	a.Extensions, a.omitted, err = decodeObject(data, (*ipAddresses)(a))
+	return err
+}
+
+func (a IPAddresses) MarshalJSON() ([]byte, error) {
+	type ipAddresses IPAddresses
+	return encodeObject(ipAddresses(a), a.Extensions, a.omitted)
+}
+
+func (v *Variants) UnmarshalJSON(data []byte) error {
+	type variants Variants
+	var err error
+	v.Extensions, v.omitted, err = decodeObject(data, (*variants)(v))
+	return err
+}
+
+func (v Variants) MarshalJSON() ([]byte, error) {
+	type variants Variants
+	return encodeObject(variants(v), v.Extensions, v.omitted)
+}
+
+func (v *VariantNames) UnmarshalJSON(data []byte) error {
+	type variantNames VariantNames
+	var err error
+	v.Extensions, v.omitted, err = decodeObject(data, (*variantNames)(v))
+	return err
+}
+
+func (v VariantNames) MarshalJSON() ([]byte, error) {
+	type variantNames VariantNames
+	return encodeObject(variantNames(v), v.Extensions, v.omitted)
+}
+
+func (s *SecureDNS) UnmarshalJSON(data []byte) error {
+	type secureDNS SecureDNS
+	var err error
+	s.Extensions, s.omitted, err = decodeObject(data, (*secureDNS)(s))
+	return err
+}
+
+func (s SecureDNS) MarshalJSON() ([]byte, error) {
+	type secureDNS SecureDNS
+	return encodeObject(secureDNS(s), s.Extensions, s.omitted)
+}
+
+func (d *DSData) UnmarshalJSON(data []byte) error {
+	type dsData DSData
+	var err error
+	d.Extensions, d.omitted, err = decodeObject(data, (*dsData)(d))
+	return err
+}
+
+func (d DSData) MarshalJSON() ([]byte, error) {
+	type dsData DSData
+	return encodeObject(dsData(d), d.Extensions, d.omitted)
+}
+
+func (k *KeyData) UnmarshalJSON(data []byte) error {
+	type keyData KeyData
+	var err error
+	k.Extensions, k.omitted, err = decodeObject(data, (*keyData)(k))
+	return err
+}
+
+func (k KeyData) MarshalJSON() ([]byte, error) {
+	type keyData KeyData
+	return encodeObject(keyData(k), k.Extensions, k.omitted)
+}
+
+func (c *Cidr0Cidrs) UnmarshalJSON(data []byte) error {
+	type cidr0Cidrs Cidr0Cidrs
+	var err error
+	c.Extensions, c.omitted, err = decodeObject(data, (*cidr0Cidrs)(c))
+	return err
+}
+
+func (c Cidr0Cidrs) MarshalJSON() ([]byte, error) {
+	type cidr0Cidrs Cidr0Cidrs
+	return encodeObject(cidr0Cidrs(c), c.Extensions, c.omitted)
+}
+
+func (e *Entity) UnmarshalJSON(data []byte) error {
+	type entity Entity
+	var err error
+	e.Extensions, e.omitted, err = decodeObject(data, (*entity)(e))
+	return err
+}
+
+func (e Entity) MarshalJSON() ([]byte, error) {
+	type entity Entity
+	return encodeObject(entity(e), e.Extensions, e.omitted)
+}
+
+func (n *Nameserver) UnmarshalJSON(data []byte) error {
+	type nameserver Nameserver
+	var err error
+	n.Extensions, n.omitted, err = decodeObject(data, (*nameserver)(n))
+	return err
+}
+
+func (n Nameserver) MarshalJSON() ([]byte, error) {
+	type nameserver Nameserver
+	return encodeObject(nameserver(n), n.Extensions, n.omitted)
+}
+
+func (d *Domain) UnmarshalJSON(data []byte) error {
+	type domain Domain
+	var err error
+	d.Extensions, d.omitted, err = decodeObject(data, (*domain)(d))
+	return err
+}
+
+func (d Domain) MarshalJSON() ([]byte, error) {
+	type domain Domain
+	return encodeObject(domain(d), d.Extensions, d.omitted)
+}
+
+func (n *IPNetwork) UnmarshalJSON(data []byte) error {
+	type ipNetwork IPNetwork
+	var err error
+	n.Extensions, n.omitted, err = decodeObject(data, (*ipNetwork)(n))
+	return err
+}
+
+func (n IPNetwork) MarshalJSON() ([]byte, error) {
+	type ipNetwork IPNetwork
+	return encodeObject(ipNetwork(n), n.Extensions, n.omitted)
+}
+
+func (a *Autonum) UnmarshalJSON(data []byte) error {
+	type autonum Autonum
+	var err error
+	a.Extensions, a.omitted, err = decodeObject(data, (*autonum)(a))
+	return err
+}
+
+func (a Autonum) MarshalJSON() ([]byte, error) {
+	type autonum Autonum
+	return encodeObject(autonum(a), a.Extensions, a.omitted)
+}
+
+// Decode an object into the fields of its type. Return the members with no
+// matching field, and the members that are present but would be omitted on
+// re-encoding. Field matching is case-insensitive, the same as encoding/json.
+func decodeObject[T any](data []byte, object *T) (map[string]json.RawMessage, map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(data, object); err != nil {
+		return nil, nil, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, nil, err
+	}
+
+	known := map[string]bool{}
+	for field := range reflect.TypeFor[T]().Fields() {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			known[strings.ToLower(name)] = true
+		}
+	}
+
+	encoded, err := json.Marshal(object)
+	if err != nil {
+		return nil, nil, err
+	}
+	written, err := memberNames(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var extensions, omitted map[string]json.RawMessage
+	for name, value := range members {
+		switch {
+		case !known[strings.ToLower(name)]:
+			if extensions == nil {
+				extensions = map[string]json.RawMessage{}
+			}
+			extensions[name] = value
+		case !written[strings.ToLower(name)]:
+			if omitted == nil {
+				omitted = map[string]json.RawMessage{}
+			}
+			omitted[name] = value
+		}
+	}
+	return extensions, omitted, nil
+}
+
+// Encode an object and append, in name order, its omitted members that are
+// still empty and its extension members
+func encodeObject(object any, extensions map[string]json.RawMessage, omitted map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(object)
+	if err != nil || len(extensions) == 0 && len(omitted) == 0 {
+		return data, err
+	}
+	written, err := memberNames(data)
+	if err != nil {
+		return nil, err
+	}
+
+	members := maps.Clone(extensions)
+	if members == nil {
+		members = map[string]json.RawMessage{}
+	}
+	for name, value := range omitted {
+		// A member set since decoding is written from its field
+		if !written[strings.ToLower(name)] {
+			members[name] = value
+		}
+	}
+
+	var buffer bytes.Buffer
+	buffer.Write(data[:len(data)-1])
+	for _, name := range slices.Sorted(maps.Keys(members)) {
+		if buffer.Len() > 1 {
+			buffer.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(members[name])
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// Lower-cased member names of an encoded object
+func memberNames(data []byte) (map[string]bool, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for name := range members {
+		names[strings.ToLower(name)] = true
+	}
+	return names, nil
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 // Bootstrap Service Registry Data Structure
 type BootstrapRegistry struct {
@@ -17,46 +21,149 @@ type BootstrapRegistry struct {
 // Links Data Structure
 // https://datatracker.ietf.org/doc/html/rfc9083#section-4.2
 type Links struct {
-	Value    string `json:"value"`
-	Rel      string `json:"rel"`
-	Href     string `json:"href"`
-	HrefLang string `json:"hreflang"`
-	Title    string `json:"title"`
-	Media    string `json:"media"`
-	Type     string `json:"type"`
+	Value      string                     `json:"value,omitempty"`
+	Rel        string                     `json:"rel,omitempty"`
+	Href       string                     `json:"href"`
+	HrefLang   string                     `json:"hreflang,omitempty"`
+	Title      string                     `json:"title,omitempty"`
+	Media      string                     `json:"media,omitempty"`
+	Type       string                     `json:"type,omitempty"`
+	Extensions map[string]json.RawMessage `json:"-"`
+	omitted    map[string]json.RawMessage
 }
 
 // Notices Data Structure
 // https://datatracker.ietf.org/doc/html/rfc9083#section-4.3
 type Notices struct {
-	Title        string   `json:"title"`
-	Descriptions []string `json:"description"`
-	Links        []Links  `json:"links"`
+	Title        string                     `json:"title,omitempty"`
+	Type         string                     `json:"type,omitempty"`
+	Descriptions []string                   `json:"description,omitempty"`
+	Links        []Links                    `json:"links,omitempty"`
+	Extensions   map[string]json.RawMessage `json:"-"`
+	omitted      map[string]json.RawMessage
 }
 
 // Remarks Data Structure
 // https://datatracker.ietf.org/doc/html/rfc9083#section-4.3
 type Remarks struct {
-	// Check RFC
-	Title        string   `json:"title"`
-	Descriptions []string `json:"description"`
-	Type         string   `json:"type"`
+	Title        string                     `json:"title,omitempty"`
+	Type         string                     `json:"type,omitempty"`
+	Descriptions []string                   `json:"description,omitempty"`
+	Links        []Links                    `json:"links,omitempty"`
+	Extensions   map[string]json.RawMessage `json:"-"`
+	omitted      map[string]json.RawMessage
 }
 
 // Events Data Structure
 // https://datatracker.ietf.org/doc/html/rfc9083#section-4.5
 type Events struct {
-	EventAction string `json:"eventAction"`
-	EventActor  string `json:"eventActor"`
-	EventDate   string `json:"eventDate"`
-	Links       Links  `json:"links"`
+	EventAction string                     `json:"eventAction"`
+	EventActor  string                     `json:"eventActor,omitempty"`
+	EventDate   string                     `json:"eventDate"`
+	Links       []Links                    `json:"links,omitempty"`
+	Extensions  map[string]json.RawMessage `json:"-"`
+	omitted     map[string]json.RawMessage
+}
+
+// Layouts accepted for event dates. RFC 9083 requires RFC 3339 but some
+// servers drop the time zone or the time.
+var eventDateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// Parse the event date, dates without a time zone are taken as UTC
+func (e Events) Date() (time.Time, error) {
+	var err error
+	for _, layout := range eventDateLayouts {
+		var date time.Time
+		if date, err = time.Parse(layout, strings.TrimSpace(e.EventDate)); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, err
 }
 
 // Public IDs Data Structure
-// JSON Responses for the Registration Data Access Protocol (RDAP)
+// https://datatracker.ietf.org/doc/html/rfc9083#section-4.8
 type PublicIds struct {
-	Type       string `json:"type"`
-	Identifier string `json:"identifier"`
+	Type       string                     `json:"type"`
+	Identifier string                     `json:"identifier"`
+	Extensions map[string]json.RawMessage `json:"-"`
+	omitted    map[string]json.RawMessage
+}
+
+// IP Addresses Data Structure (nameserver glue)
+// https://datatracker.ietf.org/doc/html/rfc9083#section-5.2
+type IPAddresses struct {
+	V6         []string                   `json:"v6,omitempty"`
+	V4         []string                   `json:"v4,omitempty"`
+	Extensions map[string]json.RawMessage `json:"-"`
+	omitted    map[string]json.RawMessage
+}
+
+// Variants Data Structure
+// https://datatracker.ietf.org/doc/html/rfc9083#section-5.3
+type Variants struct {
+	Relation     []string                   `json:"relation,omitempty"`
+	IdnTable     string                     `json:"idnTable,omitempty"`
+	VariantNames []VariantNames             `json:"variantNames,omitempty"`
+	Extensions   map[string]json.RawMessage `json:"-"`
+	omitted      map[string]json.RawMessage
+}
+
+// Variant Names Data Structure
+// https://datatracker.ietf.org/doc/html/rfc9083#section-5.3
+type VariantNames struct {
+	LdhName     string                     `json:"ldhName,omitempty"`
+	UnicodeName string                     `json:"unicodeName,omitempty"`
+	Extensions  map[string]json.RawMessage `json:"-"`
+	omitted     map[string]json.RawMessage
+}
+
+// Secure DNS Data Structure
+// https://datatracker.ietf.org/doc/html/rfc9083#section-5.3
+type SecureDNS struct {
+	ZoneSigned       *bool                      `json:"zoneSigned,omitempty"`
+	DelegationSigned *bool                      `json:"delegationSigned,omitempty"`
+	MaxSigLife       int                        `json:"maxSigLife,omitempty"`
+	DSData           []DSData                   `json:"dsData,omitempty"`
+	KeyData          []KeyData                  `json:"keyData,omitempty"`
+	Extensions       map[string]json.RawMessage `json:"-"`
+	omitted          map[string]json.RawMessage
+}
+
+// DS Data Structure
+// https://datatracker.ietf.org/doc/html/rfc9083#section-5.3
+type DSData struct {
+	KeyTag     int                        `json:"keyTag"`
+	Algorithm  int                        `json:"algorithm"`
+	Digest     string                     `json:"digest"`
+	DigestType int                        `json:"digestType"`
+	Events     []Events                   `json:"events,omitempty"`
+	Links      []Links                    `json:"links,omitempty"`
+	Extensions map[string]json.RawMessage `json:"-"`
+	omitted    map[string]json.RawMessage
+}
+
+// Key Data Structure
+// https://datatracker.ietf.org/doc/html/rfc9083#section-5.3
+type KeyData struct {
+	Flags      int                        `json:"flags"`
+	Protocol   int                        `json:"protocol"`
+	PublicKey  string                     `json:"publicKey"`
+	Algorithm  int                        `json:"algorithm"`
+	Events     []Events                   `json:"events,omitempty"`
+	Links      []Links                    `json:"links,omitempty"`
+	Extensions map[string]json.RawMessage `json:"-"`
+	omitted    map[string]json.RawMessage
+}
+
+// CIDR Data Structure (cidr0 extension)
+// https://bitbucket.org/nroecg/nro-rdap-cidr/src/master/nro-rdap-cidr.txt
+type Cidr0Cidrs struct {
+	V4Prefix   string                     `json:"v4prefix,omitempty"`
+	V6Prefix   string                     `json:"v6prefix,omitempty"`
+	Length     int                        `json:"length"`
+	Extensions map[string]json.RawMessage `json:"-"`
+	omitted    map[string]json.RawMessage
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -66,125 +173,118 @@ type PublicIds struct {
 // Entity Object Class
 // https://datatracker.ietf.org/doc/html/rfc9083#section-5.1
 type Entity struct {
-	ObjectClassName string        `json:"objectClassName"`
-	Handle          string        `json:"handle"`
-	VcardArray      []interface{} `json:"vcardArray"`
-	Roles           []string      `json:"roles"`
-	PublicIds       []PublicIds   `json:"publicIds"`
-	Entities        []Entity      `json:"entities"`
-	Remarks         []Remarks     `json:"remarks"`
-	Links           []Links       `json:"links"`
-	Events          []Events      `json:"events"`
-	AsEventActor    []Events      `json:"asEventActor"`
-	Status          []string      `json:"status"`
-	Port43          string        `json:"port43"`
-	Networks        []IPNetwork   `json:"networks"`
-	Autnums         []Autonum     `json:"autnums"`
-	RdapConformance []string      `json:"rdapConformance"`
-	Notices         []Notices     `json:"notices"`
+	ObjectClassName string                     `json:"objectClassName"`
+	Handle          string                     `json:"handle,omitempty"`
+	VcardArray      []interface{}              `json:"vcardArray,omitempty"`
+	Roles           []string                   `json:"roles,omitempty"`
+	PublicIds       []PublicIds                `json:"publicIds,omitempty"`
+	Entities        []Entity                   `json:"entities,omitempty"`
+	Remarks         []Remarks                  `json:"remarks,omitempty"`
+	Links           []Links                    `json:"links,omitempty"`
+	Events          []Events                   `json:"events,omitempty"`
+	AsEventActor    []Events                   `json:"asEventActor,omitempty"`
+	Status          []string                   `json:"status,omitempty"`
+	Port43          string                     `json:"port43,omitempty"`
+	Lang            string                     `json:"lang,omitempty"`
+	Networks        []IPNetwork                `json:"networks,omitempty"`
+	Autnums         []Autonum                  `json:"autnums,omitempty"`
+	RdapConformance []string                   `json:"rdapConformance,omitempty"`
+	Notices         []Notices                  `json:"notices,omitempty"`
+	Extensions      map[string]json.RawMessage `json:"-"`
+	omitted         map[string]json.RawMessage
 }
 
 // Nameserver Object Class
 // https://datatracker.ietf.org/doc/html/rfc9083#section-5.2
 type Nameserver struct {
-	ObjectClassName string `json:"objectClassName"`
-	Handle          string `json:"handle"`
-	LdhName         string `json:"ldhName"`
-	UnicodeName     string `json:"unicodeName"`
-	IPAddresses     struct {
-		V6 []string `json:"v6"`
-		V4 []string `json:"v4"`
-	} `json:"ipAddresses"`
-	Entities        []Entity  `json:"entities"`
-	Status          []string  `json:"status"`
-	Remarks         []Remarks `json:"remarks"`
-	Links           []Links   `json:"links"`
-	Port43          string    `json:"port43"`
-	Events          []Events  `json:"events"`
-	RdapConformance []string  `json:"rdapConformance"`
-	Notices         []Notices `json:"notices"`
+	ObjectClassName string                     `json:"objectClassName"`
+	Handle          string                     `json:"handle,omitempty"`
+	LdhName         string                     `json:"ldhName,omitempty"`
+	UnicodeName     string                     `json:"unicodeName,omitempty"`
+	IPAddresses     IPAddresses                `json:"ipAddresses,omitzero"`
+	Entities        []Entity                   `json:"entities,omitempty"`
+	Status          []string                   `json:"status,omitempty"`
+	Remarks         []Remarks                  `json:"remarks,omitempty"`
+	Links           []Links                    `json:"links,omitempty"`
+	Port43          string                     `json:"port43,omitempty"`
+	Events          []Events                   `json:"events,omitempty"`
+	Lang            string                     `json:"lang,omitempty"`
+	RdapConformance []string                   `json:"rdapConformance,omitempty"`
+	Notices         []Notices                  `json:"notices,omitempty"`
+	Extensions      map[string]json.RawMessage `json:"-"`
+	omitted         map[string]json.RawMessage
 }
 
 // Domain Object Class
 // https://datatracker.ietf.org/doc/html/rfc9083#section-5.3
 type Domain struct {
-	ObjectClassName string `json:"objectClassName"`
-	Handle          string `json:"handle"`
-	LdhName         string `json:"ldhName"`
-	UnicodeName     string `json:"unicodeName"`
-	Variants        []string
-	Nameservers     []Nameserver `json:"nameservers"`
-	SecureDNS       struct {
-		ZoneSigned       bool   `json:"zoneSigned"`
-		DelegationSigned bool   `json:"delegationSigned"`
-		MaxSigLife       string `json:"maxSigLife"`
-		DSData           []struct {
-			KeyTag     int      `json:"keyTag"`
-			Algorithm  int      `json:"algorithm"`
-			Digest     string   `json:"digest"`
-			DigestType int      `json:"digestType"`
-			Events     []Events `json:"events"`
-			Links      []Links  `json:"links"`
-		} `json:"dsData"`
-		KeyData struct {
-			Flags     string   `json:"flags"`
-			Protocol  string   `json:"protocol"`
-			PublicKey string   `json:"publicKey"`
-			Algorithm string   `json:"algorithm"`
-			Events    []Events `json:"events"`
-			Links     []Links  `json:"links"`
-		} `json:"keyData"`
-	} `json:"secureDNS"`
-	Entities        []Entity    `json:"entities"`
-	Status          []string    `json:"status"`
-	PublicIds       []PublicIds `json:"publicIds"`
-	Remarks         []Remarks   `json:"remarks"`
-	Links           []Links     `json:"links"`
-	Port43          string      `json:"port43"`
-	Events          []Events    `json:"events"`
-	Networks        []IPNetwork `json:"networks"`
-	RdapConformance []string    `json:"rdapConformance"`
-	Notices         []Notices   `json:"notices"`
+	ObjectClassName string                     `json:"objectClassName"`
+	Handle          string                     `json:"handle,omitempty"`
+	LdhName         string                     `json:"ldhName,omitempty"`
+	UnicodeName     string                     `json:"unicodeName,omitempty"`
+	Variants        []Variants                 `json:"variants,omitempty"`
+	Nameservers     []Nameserver               `json:"nameservers,omitempty"`
+	SecureDNS       SecureDNS                  `json:"secureDNS,omitzero"`
+	Entities        []Entity                   `json:"entities,omitempty"`
+	Status          []string                   `json:"status,omitempty"`
+	PublicIds       []PublicIds                `json:"publicIds,omitempty"`
+	Remarks         []Remarks                  `json:"remarks,omitempty"`
+	Links           []Links                    `json:"links,omitempty"`
+	Port43          string                     `json:"port43,omitempty"`
+	Events          []Events                   `json:"events,omitempty"`
+	Network         *IPNetwork                 `json:"network,omitempty"`
+	Lang            string                     `json:"lang,omitempty"`
+	RdapConformance []string                   `json:"rdapConformance,omitempty"`
+	Notices         []Notices                  `json:"notices,omitempty"`
+	Extensions      map[string]json.RawMessage `json:"-"`
+	omitted         map[string]json.RawMessage
 }
 
 // IP Network Object Class
 // https://datatracker.ietf.org/doc/html/rfc9083#section-5.4
 type IPNetwork struct {
-	ObjectClassName string    `json:"objectClassName"`
-	Handle          string    `json:"handle"`
-	StartAddress    string    `json:"startAddress"`
-	EndAddress      string    `json:"endAddress"`
-	IPVersion       string    `json:"ipVersion"`
-	Name            string    `json:"name"`
-	Type            string    `json:"type"`
-	Country         string    `json:"country"`
-	ParentHandle    string    `json:"parentHandle"`
-	Status          []string  `json:"status"`
-	Entities        []Entity  `json:"entities"`
-	Remarks         []Remarks `json:"remarks"`
-	Links           []Links   `json:"links"`
-	Port43          string    `json:"port43"`
-	Events          []Events  `json:"events"`
-	RdapConformance []string  `json:"rdapConformance"`
-	Notices         []Notices `json:"notices"`
+	ObjectClassName string                     `json:"objectClassName"`
+	Handle          string                     `json:"handle,omitempty"`
+	StartAddress    string                     `json:"startAddress,omitempty"`
+	EndAddress      string                     `json:"endAddress,omitempty"`
+	IPVersion       string                     `json:"ipVersion,omitempty"`
+	Name            string                     `json:"name,omitempty"`
+	Type            string                     `json:"type,omitempty"`
+	Country         string                     `json:"country,omitempty"`
+	ParentHandle    string                     `json:"parentHandle,omitempty"`
+	Status          []string                   `json:"status,omitempty"`
+	Entities        []Entity                   `json:"entities,omitempty"`
+	Remarks         []Remarks                  `json:"remarks,omitempty"`
+	Links           []Links                    `json:"links,omitempty"`
+	Port43          string                     `json:"port43,omitempty"`
+	Events          []Events                   `json:"events,omitempty"`
+	Cidr0Cidrs      []Cidr0Cidrs               `json:"cidr0_cidrs,omitempty"`
+	Lang            string                     `json:"lang,omitempty"`
+	RdapConformance []string                   `json:"rdapConformance,omitempty"`
+	Notices         []Notices                  `json:"notices,omitempty"`
+	Extensions      map[string]json.RawMessage `json:"-"`
+	omitted         map[string]json.RawMessage
 }
 
 // Autonomous System Number Object Class
 // https://datatracker.ietf.org/doc/html/rfc9083#section-5.5
 type Autonum struct {
-	ObjectClassName string    `json:"objectClassName"`
-	Handle          string    `json:"handle"`
-	StartAutnum     string    `json:"startAutnum"`
-	EndAutnum       string    `json:"endAutnum"`
-	Name            string    `json:"name"`
-	Type            string    `json:"type"`
-	Status          []string  `json:"status"`
-	Country         string    `json:"country"`
-	Entities        []Entity  `json:"entities"`
-	Remarks         []Remarks `json:"remarks"`
-	Links           []Links   `json:"links"`
-	Port43          string    `json:"port43"`
-	Events          []Events  `json:"events"`
-	RdapConformance []string  `json:"rdapConformance"`
-	Notices         []Notices `json:"notices"`
+	ObjectClassName string                     `json:"objectClassName"`
+	Handle          string                     `json:"handle,omitempty"`
+	StartAutnum     uint32                     `json:"startAutnum,omitempty"`
+	EndAutnum       uint32                     `json:"endAutnum,omitempty"`
+	Name            string                     `json:"name,omitempty"`
+	Type            string                     `json:"type,omitempty"`
+	Status          []string                   `json:"status,omitempty"`
+	Country         string                     `json:"country,omitempty"`
+	Entities        []Entity                   `json:"entities,omitempty"`
+	Remarks         []Remarks                  `json:"remarks,omitempty"`
+	Links           []Links                    `json:"links,omitempty"`
+	Port43          string                     `json:"port43,omitempty"`
+	Events          []Events                   `json:"events,omitempty"`
+	Lang            string                     `json:"lang,omitempty"`
+	RdapConformance []string                   `json:"rdapConformance,omitempty"`
+	Notices         []Notices                  `json:"notices,omitempty"`
+	Extensions      map[string]json.RawMessage `json:"-"`
+	omitted         map[string]json.RawMessage
 }
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Decode a response from testdata
+func decodeFixture(t *testing.T, name string, object any) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, object); err != nil {
+		t.Fatalf("decoding %v: %v", name, err)
+	}
+}
+
+func TestDecodeVerisignDomain(t *testing.T) {
+	var domain Domain
+	decodeFixture(t, "verisign_domain.json", &domain)
+
+	if domain.LdhName != "EXAMPLE.COM" || len(domain.Nameservers) != 2 || len(domain.Events) != 4 {
+		t.Errorf("unexpected domain: %+v", domain)
+	}
+	if len(domain.SecureDNS.DSData) != 1 || domain.SecureDNS.DSData[0].KeyTag != 370 || domain.SecureDNS.DelegationSigned == nil || !*domain.SecureDNS.DelegationSigned {
+		t.Errorf("unexpected secureDNS: %+v", domain.SecureDNS)
+	}
+	if len(domain.Notices) != 3 || len(domain.Notices[0].Descriptions) != 1 || len(domain.Notices[0].Links) != 1 {
+		t.Errorf("unexpected notices: %+v", domain.Notices)
+	}
+	if len(domain.Entities) != 1 || len(domain.Entities[0].Entities) != 1 || domain.Entities[0].PublicIds[0].Identifier != "376" {
+		t.Errorf("unexpected entities: %+v", domain.Entities)
+	}
+}
+
+func TestDecodeARINNetwork(t *testing.T) {
+	var network IPNetwork
+	decodeFixture(t, "arin_network.json", &network)
+
+	if network.Handle != "NET-192-0-2-0-1" || network.ParentHandle != "NET-192-0-0-0-0" || network.IPVersion != "v4" {
+		t.Errorf("unexpected network: %+v", network)
+	}
+	if len(network.Cidr0Cidrs) != 1 || network.Cidr0Cidrs[0].V4Prefix != "192.0.2.0" || network.Cidr0Cidrs[0].Length != 24 {
+		t.Errorf("unexpected cidr0_cidrs: %+v", network.Cidr0Cidrs)
+	}
+	if _, found := network.Extensions["arin_originas0_originautnums"]; !found {
+		t.Errorf("arin_originas0_originautnums not kept: %v", network.Extensions)
+	}
+}
+
+func TestDecodeAPNICAutnum(t *testing.T) {
+	var autnum Autonum
+	decodeFixture(t, "apnic_autnum.json", &autnum)
+
+	if autnum.StartAutnum != 4608 || autnum.EndAutnum != 4608 || autnum.Name != "APNIC-SERVICES" {
+		t.Errorf("unexpected autnum: %+v", autnum)
+	}
+	if autnum.Lang != "en" || autnum.Port43 != "whois.apnic.net" {
+		t.Errorf("lang %q, port43 %q", autnum.Lang, autnum.Port43)
+	}
+	if len(autnum.Remarks) != 1 || autnum.Remarks[0].Descriptions[0] != "Asia Pacific Network Information Centre" {
+		t.Errorf("unexpected remarks: %+v", autnum.Remarks)
+	}
+}
+
+func TestDecodeReverseDomainNetwork(t *testing.T) {
+	var domain Domain
+	decodeFixture(t, "ripe_reverse_domain.json", &domain)
+
+	if domain.Network == nil {
+		t.Fatal("network not decoded")
+	}
+	if domain.Network.StartAddress != "192.0.2.0" || len(domain.Network.Cidr0Cidrs) != 1 {
+		t.Errorf("unexpected network: %+v", domain.Network)
+	}
+	if domain.Lang != "en" {
+		t.Errorf("lang %q", domain.Lang)
+	}
+}
+
+func TestDecodeVariantsAndKeyData(t *testing.T) {
+	var domain Domain
+	decodeFixture(t, "idn_domain_variants.json", &domain)
+
+	if len(domain.Variants) != 2 || len(domain.Variants[0].VariantNames) != 2 || domain.Variants[1].IdnTable != ".EXAMPLE Swedish" {
+		t.Errorf("unexpected variants: %+v", domain.Variants)
+	}
+	if domain.Variants[0].VariantNames[1].UnicodeName != "föo.example" {
+		t.Errorf("unexpected variant name: %+v", domain.Variants[0].VariantNames[1])
+	}
+
+	keys := domain.SecureDNS.KeyData
+	if len(keys) != 1 || keys[0].Flags != 257 || keys[0].Protocol != 3 || keys[0].Algorithm != 8 || len(keys[0].Events) != 1 {
+		t.Errorf("unexpected keyData: %+v", keys)
+	}
+	if domain.SecureDNS.MaxSigLife != 604800 {
+		t.Errorf("maxSigLife %v", domain.SecureDNS.MaxSigLife)
+	}
+
+	if len(domain.Events[0].Links) != 1 || domain.Events[0].Links[0].Rel != "related" {
+		t.Errorf("unexpected event links: %+v", domain.Events[0].Links)
+	}
+	if domain.Events[1].EventActor != "joe@example.com" {
+		t.Errorf("eventActor %q", domain.Events[1].EventActor)
+	}
+	if domain.Lang != "sv" {
+		t.Errorf("lang %q", domain.Lang)
+	}
+}
+
+// Decoding and re-encoding a response gives back the same JSON, including
+// unknown members and members that are present but empty
+func TestRoundTrip(t *testing.T) {
+	fixtures := map[string]func() any{
+		"verisign_domain.json":     func() any { return &Domain{} },
+		"arin_network.json":        func() any { return &IPNetwork{} },
+		"apnic_autnum.json":        func() any { return &Autonum{} },
+		"ripe_reverse_domain.json": func() any { return &Domain{} },
+		"idn_domain_variants.json": func() any { return &Domain{} },
+	}
+
+	for name, object := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := object()
+			if err := json.Unmarshal(data, decoded); err != nil {
+				t.Fatal(err)
+			}
+			encoded, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want, got any
+			json.Unmarshal(data, &want)
+			json.Unmarshal(encoded, &got)
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("re-encoded JSON differs:\nwant %s\ngot  %s", compact(t, data), encoded)
+			}
+		})
+	}
+}
+
+// A member set after decoding replaces the empty value it was decoded with
+func TestRoundTripModified(t *testing.T) {
+	var domain Domain
+	decodeFixture(t, "ripe_reverse_domain.json", &domain)
+	domain.Port43 = "whois.ripe.net"
+
+	encoded, err := json.Marshal(domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var members map[string]any
+	json.Unmarshal(encoded, &members)
+	if members["port43"] != "whois.ripe.net" {
+		t.Errorf("port43 %v in %s", members["port43"], encoded)
+	}
+}
+
+func compact(t *testing.T, data []byte) string {
+	var buffer bytes.Buffer
+	if err := json.Compact(&buffer, data); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
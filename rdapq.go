@@ -1,8 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	s "github.com/kadonnelly13/rdapq/services"
 )
@@ -12,23 +17,531 @@ const (
 )
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "history":
+			historyCommand(os.Args[2:])
+			return
+		case "diff":
+			diffCommand(os.Args[2:])
+			return
+		case "watch":
+			watchCommand(os.Args[2:])
+			return
+		case "expiry":
+			expiryCommand(os.Args[2:])
+			return
+		case "timeline":
+			timelineCommand(os.Args[2:])
+			return
+		case "cluster":
+			clusterCommand(os.Args[2:])
+			return
+		case "dsverify":
+			dsVerifyCommand(os.Args[2:])
+			return
+		case "probe":
+			probeCommand(os.Args[2:])
+			return
+		}
+	}
+
 	domain := flag.String("domain", "", "Enter FQDN")
 	ipv4 := flag.String("ipv4", "", "Enter IPv4 address without CIDR range")
-	outputLocation := flag.String("output", "", "Output results into JSON file at this location and filename\n(ex. -output=./test.json")
+	asn := flag.String("asn", "", "Enter autonomous system number (ex. -asn=AS15133)")
+	inputLocation := flag.String("input", "", "Query every domain, IPv4 address and ASN listed in this file, one per line")
+	outputLocation := flag.String("output", "", "Output results into a file at this location and filename\n(ex. -output=./test.json")
+	format := flag.String("format", "text", "Output format: "+strings.Join(s.Formats, ", ")+"\nWithout -output, any format other than text is written to stdout")
+	rawOutput := flag.Bool("raw", false, "Write the unmodified RDAP server responses instead of the decoded data (json and ndjson)")
+	historyLocation := flag.String("db", "", "Save every lookup to the SQLite history database at this location\n(ex. -db=./rdapq.db)")
+	sink := flag.String("sink", "", "Also send summary records to a SIEM: "+strings.Join(s.Sinks, ", "))
+	sinkURL := flag.String("sink-url", "", "Splunk HEC or Elasticsearch base URL")
+	sinkToken := flag.String("sink-token", "", "Splunk HEC token or Elasticsearch API key (default $RDAPQ_SINK_TOKEN)")
+	sinkIndex := flag.String("sink-index", "", "Splunk index or Elasticsearch index (Elasticsearch default \"rdapq\")")
+	sinkSourceType := flag.String("sink-sourcetype", "rdapq:summary", "Splunk sourcetype")
+	sinkBatch := flag.Int("sink-batch", 100, "Number of results sent per request")
+	sinkRetries := flag.Int("sink-retries", 3, "Number of retries for a failed request")
+	followDepth := flag.Int("follow-depth", 0, "Follow links from each result this many levels deep")
+	followRels := flag.String("follow-rels", strings.Join(s.DefaultTraverseRels, ","), "Comma separated link relations to follow (\"entity\" follows entity self links)")
+	followBudget := flag.Int("follow-budget", 20, "Maximum number of linked objects fetched per run")
+	hierarchy := flag.Bool("hierarchy", false, "Walk IP networks up to the top allocation and down to more specific networks (uses -follow-budget)")
+	enrichNS := flag.Bool("enrich-ns", false, "Look up the network and origin ASN of every nameserver glue address")
+	riskConfig := flag.String("risk-config", "", "JSON file overriding the domain risk score weights and thresholds")
 	flag.Parse()
 
-	if *domain != "" && *ipv4 != "" {
-		fmt.Printf("\n(!) You have provided too many flags. Choose to query on a domain or an IPv4 address.")
+	if *riskConfig != "" {
+		weights, err := s.LoadRiskWeights(*riskConfig)
+		if err != nil {
+			fmt.Printf("\n(!) Error reading risk config:\n%v\n", err)
+			os.Exit(1)
+		}
+		s.RiskConfig = weights
+	}
+
+	if !slices.Contains(s.Formats, *format) {
+		fmt.Printf("\n(!) Unknown output format %q. Choose one of: %v\n", *format, strings.Join(s.Formats, ", "))
+		os.Exit(1)
+	}
+
+	if *sink != "" && !slices.Contains(s.Sinks, *sink) {
+		fmt.Printf("\n(!) Unknown sink %q. Choose one of: %v\n", *sink, strings.Join(s.Sinks, ", "))
+		os.Exit(1)
+	}
+	if *sinkToken == "" {
+		*sinkToken = os.Getenv("RDAPQ_SINK_TOKEN")
+	}
+
+	// "down" links lead to searches, which only -hierarchy decodes
+	if slices.Contains(strings.Split(*followRels, ","), "down") {
+		fmt.Printf("\n(!) -follow-rels cannot follow \"down\" links, use -hierarchy to walk to more specific networks\n")
+		os.Exit(1)
+	}
+
+	// Keep stdout clean for piping results
+	if *format != "text" && *outputLocation == "" {
+		s.Status = os.Stderr
+	}
+
+	options := s.Options{
+		OutputLocation:  *outputLocation,
+		Format:          *format,
+		RawOutput:       *rawOutput,
+		HistoryLocation: *historyLocation,
+		Hierarchy:       *hierarchy,
+		EnrichNS:        *enrichNS,
+		RegistryBaseURL: RDAPServiceRegistryURL,
+		Traverse: s.TraverseOptions{
+			Rels:   strings.Split(*followRels, ","),
+			Depth:  *followDepth,
+			Budget: *followBudget,
+		},
+		Sink: s.SinkOptions{
+			Type:       *sink,
+			URL:        *sinkURL,
+			Token:      *sinkToken,
+			Index:      *sinkIndex,
+			SourceType: *sinkSourceType,
+			BatchSize:  *sinkBatch,
+			Retries:    *sinkRetries,
+		},
+	}
+
+	queryFlags := 0
+	for _, query := range []string{*domain, *ipv4, *asn, *inputLocation} {
+		if query != "" {
+			queryFlags++
+		}
+	}
+
+	if queryFlags > 1 {
+		fmt.Fprintf(s.Status, "\n(!) You have provided too many flags. Choose to query on a domain, an IPv4 address, an ASN or an input file.")
 	} else if *domain != "" {
-		fmt.Printf("\n(+) Querying RDAP Service for domain:\t%v", *domain)
+		fmt.Fprintf(s.Status, "\n(+) Querying RDAP Service for domain:\t%v", *domain)
 		registryURL := RDAPServiceRegistryURL + "dns.json"
-		s.GetDomainData(domain, registryURL, outputLocation)
+		s.GetDomainData(*domain, registryURL, options)
 	} else if *ipv4 != "" {
-		fmt.Printf("\n(+) Querying RDAP Service for IPv4 address:\t\t%v", *ipv4)
+		fmt.Fprintf(s.Status, "\n(+) Querying RDAP Service for IPv4 address:\t\t%v", *ipv4)
 		registryURL := RDAPServiceRegistryURL + "ipv4.json"
-		s.GetIPv4Data(ipv4, registryURL, outputLocation)
+		s.GetIPv4Data(*ipv4, registryURL, options)
+	} else if *asn != "" {
+		fmt.Fprintf(s.Status, "\n(+) Querying RDAP Service for ASN:\t\t%v", *asn)
+		registryURL := RDAPServiceRegistryURL + "asn.json"
+		s.GetAutonumData(*asn, registryURL, options)
+	} else if *inputLocation != "" {
+		indicators, err := s.ReadIndicators(*inputLocation)
+		if err != nil {
+			fmt.Fprintf(s.Status, "\n(!) Error reading input file:\n%v\n", err)
+			os.Exit(1)
+		}
+		s.GetBulkData(indicators, RDAPServiceRegistryURL, options)
 	} else {
-		fmt.Printf("\n(!) You have provided no search flags. Choose to query on a domain or an IPv4 address.")
+		fmt.Printf("\n(!) You have provided no search flags. Choose to query on a domain, an IPv4 address, an ASN or an input file.")
 		flag.PrintDefaults()
 	}
 }
+
+// List stored snapshots of an indicator
+func historyCommand(arguments []string) {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	historyLocation := flags.String("db", "./rdapq.db", "SQLite history database location")
+	id := flags.Int64("id", 0, "Print the raw response stored in one snapshot")
+	format := flags.String("format", "text", "Output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: rdapq history [flags] <domain|ipv4|asn>\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	if *format != "text" && *format != "json" {
+		fmt.Printf("\n(!) Unknown output format %q. Choose one of: text, json\n", *format)
+		os.Exit(1)
+	}
+
+	history, err := s.ReadHistory(*historyLocation)
+	if err != nil {
+		fmt.Printf("\n(!) %v\n", err)
+		os.Exit(1)
+	}
+	defer history.Close()
+
+	if *id != 0 {
+		snapshot, err := history.Snapshot(*id)
+		if err != nil {
+			fmt.Printf("\n(!) %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s\n", snapshot.Raw)
+		return
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	snapshots, err := history.Snapshots(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("\n(!) %v\n", err)
+		os.Exit(1)
+	}
+
+	if *format == "json" {
+		output, _ := json.MarshalIndent(snapshots, "", "\t")
+		fmt.Printf("%s\n", output)
+		return
+	}
+	if len(snapshots) == 0 {
+		fmt.Printf("(!) No snapshots of %v in %v\n", flags.Arg(0), *historyLocation)
+		return
+	}
+	s.PrintSnapshots(os.Stdout, snapshots)
+}
+
+// Compare two saved results, or a stored snapshot with a file or live query
+func diffCommand(arguments []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	historyLocation := flags.String("db", "./rdapq.db", "SQLite history database location, used with -snapshot")
+	snapshotID := flags.Int64("snapshot", 0, "Compare this stored snapshot with a file, or with a live query when no file is given")
+	format := flags.String("format", "text", "Output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: rdapq diff [flags] <old.json> <new.json>\n       rdapq diff -db=./rdapq.db -snapshot=<id> [new.json]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	var oldResults, newResults []s.Result
+	var err error
+
+	if *snapshotID != 0 {
+		oldResults, newResults, err = snapshotResults(*historyLocation, *snapshotID, flags.Args())
+	} else {
+		if flags.NArg() != 2 {
+			flags.Usage()
+			os.Exit(1)
+		}
+		oldResults, err = s.LoadResults(flags.Arg(0))
+		if err == nil {
+			newResults, err = s.LoadResults(flags.Arg(1))
+		}
+	}
+	if err != nil {
+		fmt.Printf("\n(!) %v\n", err)
+		os.Exit(1)
+	}
+
+	// Saved files may hold several responses (registry and registrar), each is
+	// compared with the response from the same server
+	type diff struct {
+		Old     string     `json:"old"`
+		New     string     `json:"new"`
+		Changes []s.Change `json:"changes"`
+	}
+	diffs := []diff{}
+	for _, pair := range s.PairResults(oldResults, newResults) {
+		changes := s.DiffResults(pair.Old, pair.New)
+		if *format == "json" {
+			if changes == nil {
+				changes = []s.Change{}
+			}
+			diffs = append(diffs, diff{Old: pair.Old.ServerURL, New: pair.New.ServerURL, Changes: changes})
+		} else {
+			s.PrintChanges(os.Stdout, pair.Old, pair.New, changes)
+		}
+	}
+
+	if *format == "json" {
+		output, _ := json.MarshalIndent(diffs, "", "\t")
+		fmt.Printf("%s\n", output)
+	}
+}
+
+// Load a stored snapshot and what to compare it with: a saved file, or a live
+// query of the same server
+func snapshotResults(historyLocation string, snapshotID int64, files []string) ([]s.Result, []s.Result, error) {
+	history, err := s.ReadHistory(historyLocation)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshot, err := history.Snapshot(snapshotID)
+	history.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	oldResult, err := snapshot.Result()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding snapshot %v:\n%v", snapshotID, err)
+	}
+
+	if len(files) == 1 {
+		newResults, err := s.LoadResults(files[0])
+		return []s.Result{oldResult}, newResults, err
+	}
+
+	s.Status = os.Stderr
+	fmt.Fprintf(s.Status, "\n(+) Querying RDAP Service for:\t%v", snapshot.Query)
+	newResults, err := s.LookupIndicator(snapshot.Query, RDAPServiceRegistryURL)
+	fmt.Fprintf(s.Status, "\n")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, result := range newResults {
+		if result.ServerURL == snapshot.Server {
+			return []s.Result{oldResult}, []s.Result{result}, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%v no longer answers for %v", snapshot.Server, snapshot.Query)
+}
+
+// Re-query a list of indicators and alert on changes
+func watchCommand(arguments []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	inputLocation := flags.String("input", "", "File listing the domains, IPv4 addresses and ASNs to watch, one per line")
+	interval := flags.Duration("interval", time.Hour, "Time between checks")
+	fields := flags.String("fields", strings.Join(s.DefaultWatchFields, ","), "Comma separated fields to alert on, matched as prefixes of diff fields")
+	format := flags.String("format", "text", "Alert format: text or ndjson")
+	webhook := flags.String("webhook", "", "Also POST each alert as JSON to this URL")
+	historyLocation := flags.String("db", "", "SQLite history database, keeps the previous snapshot between runs")
+	once := flags.Bool("once", false, "Check once and exit, for running from cron with -db")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: rdapq watch -input=<file> [flags] [indicator...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	indicators := commandIndicators(flags, *inputLocation, *format, "text", "ndjson")
+
+	if *interval < s.MinimumWatchInterval {
+		fmt.Printf("\n(!) -interval must be at least %v\n", s.MinimumWatchInterval)
+		os.Exit(1)
+	}
+
+	s.Watch(indicators, RDAPServiceRegistryURL, s.WatchOptions{
+		Interval:        *interval,
+		Fields:          strings.Split(*fields, ","),
+		Format:          *format,
+		Webhook:         *webhook,
+		HistoryLocation: *historyLocation,
+		Once:            *once,
+	})
+}
+
+// Report days until expiration for a list of domains. Exits 2 when any domain
+// is expired or inside the critical window, and 1 when a domain could not be
+// checked, so it can be run from cron.
+func expiryCommand(arguments []string) {
+	flags := flag.NewFlagSet("expiry", flag.ExitOnError)
+	inputLocation := flags.String("input", "", "File listing the domains to check, one per line")
+	warning := flags.Int("warning", 30, "Warn when a domain expires within this many days")
+	critical := flags.Int("critical", 7, "Exit non-zero when a domain expires within this many days")
+	format := flags.String("format", "text", "Output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: rdapq expiry -input=<file> [flags] [domain...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	domains := commandIndicators(flags, *inputLocation, *format, "text", "json")
+
+	expiries := s.CheckExpiry(domains, RDAPServiceRegistryURL+"dns.json", *warning, *critical)
+	fmt.Fprintf(s.Status, "\n")
+	if *format == "json" {
+		s.WriteExpiryJSON(os.Stdout, expiries)
+	} else {
+		s.PrintExpiry(os.Stdout, expiries)
+	}
+
+	os.Exit(s.ExpiryExitCode(expiries))
+}
+
+// Print the events of the registry and registrar responses as one timeline
+func timelineCommand(arguments []string) {
+	flags := flag.NewFlagSet("timeline", flag.ExitOnError)
+	inputLocation := flags.String("input", "", "File listing the domains, IPv4 addresses and ASNs to include, one per line")
+	format := flags.String("format", "text", "Output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: rdapq timeline [flags] indicator...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	indicators := commandIndicators(flags, *inputLocation, *format, "text", "json")
+
+	results := s.LookupIndicators(indicators, RDAPServiceRegistryURL)
+	fmt.Fprintf(s.Status, "\n")
+	if len(results) == 0 {
+		os.Exit(1)
+	}
+
+	timeline := s.Timeline(results)
+	if *format == "json" {
+		s.WriteTimelineJSON(os.Stdout, timeline)
+	} else {
+		s.PrintTimeline(os.Stdout, timeline)
+	}
+}
+
+// Look up a batch of indicators and group them by shared attributes
+func clusterCommand(arguments []string) {
+	flags := flag.NewFlagSet("cluster", flag.ExitOnError)
+	inputLocation := flags.String("input", "", "File listing the domains, IPv4 addresses and ASNs to cluster, one per line")
+	keys := flags.String("keys", strings.Join(s.ClusterKeys, ","), "Comma separated attributes to cluster on: "+strings.Join(s.ClusterKeys, ", "))
+	minSize := flags.Int("min-size", 2, "Smallest number of indicators reported as a cluster")
+	format := flags.String("format", "text", "Output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: rdapq cluster -input=<file> [flags] [indicator...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	indicators := commandIndicators(flags, *inputLocation, *format, "text", "json")
+	clusterKeys := strings.Split(*keys, ",")
+	for _, key := range clusterKeys {
+		if !slices.Contains(s.ClusterKeys, key) {
+			fmt.Printf("\n(!) Unknown cluster key %q. Choose from: %v\n", key, strings.Join(s.ClusterKeys, ", "))
+			os.Exit(1)
+		}
+	}
+
+	results := s.LookupIndicators(indicators, RDAPServiceRegistryURL)
+	fmt.Fprintf(s.Status, "\n")
+
+	// Domains are clustered on the networks hosting their nameservers
+	var hosts []s.NameserverHost
+	if slices.Contains(clusterKeys, "network") {
+		hosts = s.NameserverHosts(append(results, s.EnrichNameservers(results, RDAPServiceRegistryURL)...))
+	}
+
+	clusters := s.ClusterResults(results, hosts, clusterKeys, *minSize)
+	if *format == "json" {
+		s.WriteClustersJSON(os.Stdout, clusters)
+	} else {
+		s.PrintClusters(os.Stdout, clusters)
+	}
+}
+
+// Check a domain's published DS records against a local DNSKEY file. Exits 2
+// when a DS record is orphaned or a key has no DS record.
+func dsVerifyCommand(arguments []string) {
+	flags := flag.NewFlagSet("dsverify", flag.ExitOnError)
+	keysLocation := flags.String("keys", "", "Zone file or dnssec-keygen .key file holding the expected DNSKEY records")
+	format := flags.String("format", "text", "Output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: rdapq dsverify -keys=<file> [flags] domain\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	if *keysLocation == "" || flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	domain := commandIndicators(flags, "", *format, "text", "json")[0]
+
+	keys, err := s.ReadDNSKEYs(*keysLocation)
+	if err != nil {
+		fmt.Printf("\n(!) Error reading keys file:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(s.Status, "\n(+) Querying RDAP Service for domain:\t%v", domain)
+	results, err := s.LookupDomain(domain, RDAPServiceRegistryURL+"dns.json")
+	if err != nil {
+		fmt.Fprintf(s.Status, "\n(!) %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(s.Status, "\n")
+
+	// The registry's DS set is the one DNS resolvers see
+	registry := results[0]
+	checks := s.VerifyDS(domain, registry.Domain.SecureDNS.DSData, keys)
+	if *format == "json" {
+		s.WriteDSChecksJSON(os.Stdout, checks)
+	} else {
+		s.PrintDSChecks(os.Stdout, domain, registry.ServerURL, checks)
+	}
+
+	for _, check := range checks {
+		if check.Status == "orphaned" || check.Status == "missing" {
+			os.Exit(2)
+		}
+	}
+}
+
+// Report what the RDAP servers of TLDs, IP prefixes, ASNs or base URLs
+// support
+func probeCommand(arguments []string) {
+	flags := flag.NewFlagSet("probe", flag.ExitOnError)
+	inputLocation := flags.String("input", "", "File listing the TLDs, IP prefixes, ASNs and base URLs to probe, one per line")
+	format := flags.String("format", "text", "Output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: rdapq probe [flags] [tld|prefix|asn|url...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(arguments)
+
+	targets := commandIndicators(flags, *inputLocation, *format, "text", "json")
+
+	var reports []s.ProbeReport
+	for _, target := range targets {
+		baseURL, lookupPath, err := s.ProbeBaseURL(target, RDAPServiceRegistryURL)
+		if err != nil {
+			fmt.Fprintf(s.Status, "\n(!) No RDAP server found for %v: %v", target, err)
+			continue
+		}
+		reports = append(reports, s.Probe(target, baseURL, lookupPath))
+	}
+	fmt.Fprintf(s.Status, "\n")
+
+	if *format == "json" {
+		s.WriteProbeReportsJSON(os.Stdout, reports)
+	} else {
+		s.PrintProbeReports(os.Stdout, reports)
+	}
+}
+
+// Collect the indicators given as arguments and in an -input file and check
+// -format against the formats a subcommand writes. The subcommand's output
+// goes to stdout from here on and progress to stderr.
+func commandIndicators(flags *flag.FlagSet, inputLocation string, format string, formats ...string) []string {
+	indicators := flags.Args()
+	if inputLocation != "" {
+		fileIndicators, err := s.ReadIndicators(inputLocation)
+		if err != nil {
+			fmt.Printf("\n(!) Error reading input file:\n%v\n", err)
+			os.Exit(1)
+		}
+		indicators = append(indicators, fileIndicators...)
+	}
+	if len(indicators) == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+	if !slices.Contains(formats, format) {
+		fmt.Printf("\n(!) Unknown output format %q. Choose one of: %v\n", format, strings.Join(formats, ", "))
+		os.Exit(1)
+	}
+
+	s.Status = os.Stderr
+	return indicators
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+func GetAutonumData(asn string, registryURL string, options Options) {
+	authoritativeServerData, err := LookupAutonum(asn, registryURL)
+	if err != nil {
+		fmt.Fprintf(Status, "\n(!) %v\n", err)
+		os.Exit(1)
+	}
+
+	WriteResults([]Result{authoritativeServerData}, options)
+
+	fmt.Fprintf(Status, "\n\n($) Query Completed\n\n")
+}
+
+// Look up an autonomous system number, with or without the "AS" prefix
+func LookupAutonum(asn string, registryURL string) (Result, error) {
+	number, err := parseASN(asn)
+	if err != nil {
+		return Result{}, err
+	}
+	URL, err := getAuthoritativeASNServerURL(number, registryURL)
+	if err != nil {
+		return Result{}, err
+	}
+	autnumURL := URL + "autnum/" + strconv.FormatUint(uint64(number), 10)
+
+	return queryAuthoritativeASNServer(asn, autnumURL)
+}
+
+// https://datatracker.ietf.org/doc/html/rfc9224#section-5.3
+func getAuthoritativeASNServerURL(number uint32, registryURL string) (string, error) {
+	fmt.Fprintf(Status, "\n(+) Finding Authoritative Service URL for ASN:\t%v", number)
+
+	bootstrapRegistryData, err := getBootstrapRegistry(registryURL)
+	if err != nil {
+		return "", err
+	}
+
+	// Services are listed as ranges of ASNs ("64512-65534") or single ASNs
+	for _, service := range bootstrapRegistryData.Services {
+		for _, serviceRange := range service[0] {
+			first, last, found := strings.Cut(serviceRange, "-")
+			if !found {
+				last = first
+			}
+			start, err := strconv.ParseUint(first, 10, 32)
+			if err != nil {
+				continue
+			}
+			end, err := strconv.ParseUint(last, 10, 32)
+			if err != nil {
+				continue
+			}
+			if uint64(number) >= start && uint64(number) <= end {
+				fmt.Fprintf(Status, "\n(+) Service URL for ASN Range '%s':\t\t%v", serviceRange, service[1][0])
+				return service[1][0], nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no RDAP service found for AS%v", number)
+}
+
+func queryAuthoritativeASNServer(asn string, RDAPServerURL string) (Result, error) {
+	var ResponseData m.Autonum
+
+	queryResponseBody, capabilities, err := queryRDAPServer(RDAPServerURL)
+	if err != nil {
+		return Result{}, err
+	}
+
+	err = json.Unmarshal(queryResponseBody, &ResponseData)
+	if err != nil {
+		return Result{}, fmt.Errorf("error un-marshalling query response body:\n%v", err)
+	}
+
+	return Result{Query: asn, ServerURL: RDAPServerURL, FetchedAt: time.Now().UTC(), Raw: queryResponseBody, Capabilities: capabilities, Autonum: &ResponseData}, nil
+}
+
+// Parse "AS15133" or "15133" into an ASN
+func parseASN(asn string) (uint32, error) {
+	trimmed := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(asn)), "AS")
+	number, err := strconv.ParseUint(trimmed, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing ASN %q", asn)
+	}
+	return uint32(number), nil
+}
+
+// ASN an autnum result was looked up for. Registries answer with the block
+// holding it, so startAutnum is only used when the query is not an ASN, such
+// as a followed link.
+func queriedASN(result Result) uint32 {
+	if number, err := parseASN(result.Query); err == nil {
+		return number
+	}
+	return result.Autonum.StartAutnum
+}
+
+// Pretty print autnum data
+func prettyPrintAutonumData(w io.Writer, serverResponseData m.Autonum) {
+	fmt.Fprintf(w, "\n\nRDAP Query Results")
+	fmt.Fprintf(w, "\n---------------------------------------------------------------")
+	fmt.Fprintf(w, "\nAS Handle:\t\t%v", serverResponseData.Handle)
+	fmt.Fprintf(w, "\nAS Name:\t\t%v", serverResponseData.Name)
+	fmt.Fprintf(w, "\nAS Type:\t\t%v", serverResponseData.Type)
+	fmt.Fprintf(w, "\nStart Autnum:\t\t%v", serverResponseData.StartAutnum)
+	fmt.Fprintf(w, "\nEnd Autnum:\t\t%v", serverResponseData.EndAutnum)
+	fmt.Fprintf(w, "\nCountry:\t\t%v", serverResponseData.Country)
+
+	printStatuses(w, "Statuses", serverResponseData.Status)
+	printEvents(w, "Latest Events", serverResponseData.Events)
+	printNotices(w, serverResponseData.Notices)
+	printEntities(w, serverResponseData.Entities)
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Extensions a server supports, detected from the rdapConformance member of
+// its responses
+// https://datatracker.ietf.org/doc/html/rfc9083#section-4.1
+// https://www.iana.org/assignments/rdap-extensions/rdap-extensions.xhtml
+
+// Capabilities detected from rdapConformance. Versioned identifiers such as
+// "icann_rdap_response_profile_1" or "nro_rdap_profile_asn_flat_0" count as
+// the capability they start with.
+var CapabilityNames = []string{
+	"icann_rdap_response_profile",
+	"icann_rdap_technical_implementation_guide",
+	"redacted",
+	"paging",
+	"sorting",
+	"reverse_search",
+	"cidr0",
+	"arin_originas0",
+	"nro_rdap_profile",
+	"jscontact",
+}
+
+// Return the capabilities advertised by rdapConformance identifiers
+func Capabilities(conformance []string) []string {
+	var capabilities []string
+	for _, name := range CapabilityNames {
+		if slices.ContainsFunc(conformance, func(identifier string) bool {
+			return strings.HasPrefix(strings.ToLower(identifier), name)
+		}) {
+			capabilities = append(capabilities, name)
+		}
+	}
+	return capabilities
+}
+
+// Capabilities of each server, by scheme and host, kept from its first
+// response on. rdapConformance only lists the extensions used in a response,
+// so capabilities a later response advertises are added. Later requests to
+// the server are made with the parameters they allow.
+var (
+	serverCapabilities      = map[string][]string{}
+	serverCapabilitiesMutex sync.Mutex
+)
+
+// Searches by the path segment naming them, with the property results are
+// sorted on where the server supports sorting. Searches without a sort
+// property defined for their objects are not sorted.
+// https://datatracker.ietf.org/doc/html/rfc9082#section-3.2
+// https://datatracker.ietf.org/doc/html/rfc8977#section-2.3.1
+// https://datatracker.ietf.org/doc/html/rfc9910#section-3
+var searchSortProperties = map[string]string{
+	"domains":     "name",
+	"nameservers": "name",
+	"entities":    "handle",
+	"ips":         "",
+	"autnums":     "",
+}
+
+func serverOrigin(serverURL string) string {
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Scheme + "://" + parsed.Host)
+}
+
+// Record the capabilities a server advertises in a response and return all
+// it has advertised so far. Search results carry rdapConformance only at the
+// top level, so this is read from the whole response body.
+func recordCapabilities(serverURL string, body []byte) []string {
+	var response struct {
+		RdapConformance []string `json:"rdapConformance"`
+	}
+	json.Unmarshal(body, &response)
+
+	serverCapabilitiesMutex.Lock()
+	defer serverCapabilitiesMutex.Unlock()
+	origin := serverOrigin(serverURL)
+	if len(response.RdapConformance) > 0 {
+		serverCapabilities[origin] = Capabilities(append(slices.Clone(serverCapabilities[origin]), response.RdapConformance...))
+	}
+	return slices.Clone(serverCapabilities[origin])
+}
+
+// Return the recorded capabilities of the server of a URL
+func knownCapabilities(serverURL string) ([]string, bool) {
+	serverCapabilitiesMutex.Lock()
+	defer serverCapabilitiesMutex.Unlock()
+	capabilities, known := serverCapabilities[serverOrigin(serverURL)]
+	return capabilities, known
+}
+
+// Add the parameters a server's capabilities allow to a request: JSContact
+// in place of jCard, and for searches the total result count (paging) and a
+// sort order (sorting). Parameters already in the URL, such as the cursor of
+// a next page link, are kept.
+// https://datatracker.ietf.org/doc/html/rfc8977#section-2
+// https://datatracker.ietf.org/doc/draft-ietf-regext-rdap-jscontact/
+func capabilityRequestURL(requestURL string) string {
+	capabilities, known := knownCapabilities(requestURL)
+	if !known {
+		return requestURL
+	}
+	parsed, err := url.Parse(requestURL)
+	if err != nil {
+		return requestURL
+	}
+
+	query := parsed.Query()
+	added := false
+	add := func(name string, value string) {
+		if value != "" && !query.Has(name) {
+			query.Set(name, value)
+			added = true
+		}
+	}
+	if slices.Contains(capabilities, "jscontact") {
+		add("jscard", "1")
+	}
+	for _, segment := range strings.Split(parsed.Path, "/") {
+		property, search := searchSortProperties[segment]
+		if !search {
+			continue
+		}
+		if slices.Contains(capabilities, "paging") {
+			add("count", "true")
+		}
+		if slices.Contains(capabilities, "sorting") {
+			add("sort", property)
+		}
+		break
+	}
+
+	if !added {
+		return requestURL
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// Capabilities of the server a result came from, as recorded when it was
+// fetched. Results read back from a file fall back to their own
+// rdapConformance.
+func (result Result) capabilities() []string {
+	if result.Capabilities != nil {
+		return result.Capabilities
+	}
+	var response struct {
+		RdapConformance []string `json:"rdapConformance"`
+	}
+	json.Unmarshal(result.Raw, &response)
+	return Capabilities(response.RdapConformance)
+}
+
+// Report whether the server of a result advertised a capability
+func (result Result) Supports(capability string) bool {
+	return slices.Contains(result.capabilities(), capability)
+}
+
+// Names of the fields a response declares redacted, such as "Registrant
+// Email". Only read when the server advertises the extension.
+// https://datatracker.ietf.org/doc/html/rfc9537#section-4.2
+func redactedFields(result Result) []string {
+	if !result.Supports("redacted") {
+		return nil
+	}
+	var response struct {
+		Redacted []struct {
+			Name struct {
+				Type        string `json:"type"`
+				Description string `json:"description"`
+			} `json:"name"`
+		} `json:"redacted"`
+	}
+	json.Unmarshal(result.Raw, &response)
+
+	var fields []string
+	for _, redacted := range response.Redacted {
+		if name := firstNonEmpty(redacted.Name.Type, redacted.Name.Description); name != "" {
+			fields = append(fields, strings.ToLower(name))
+		}
+	}
+	return fields
+}
+
+// Next page of a paged search response, only followed when the server
+// advertised paging in its first response
+// https://datatracker.ietf.org/doc/html/rfc8977#section-2.2
+func nextPageURL(serverURL string, raw []byte) string {
+	if capabilities, _ := knownCapabilities(serverURL); !slices.Contains(capabilities, "paging") {
+		return ""
+	}
+	var response struct {
+		PagingMetadata struct {
+			Links []struct {
+				Rel  string `json:"rel"`
+				Href string `json:"href"`
+			} `json:"links"`
+		} `json:"paging_metadata"`
+	}
+	json.Unmarshal(raw, &response)
+	for _, link := range response.PagingMetadata.Links {
+		if link.Rel == "next" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// Print the capabilities of the server a result came from
+func printCapabilities(w io.Writer, capabilities []string) {
+	fmt.Fprintf(w, "\n\nServer Capabilities:\t%v", firstNonEmpty(strings.Join(capabilities, ", "), "none advertised"))
+}
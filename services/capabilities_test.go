@@ -0,0 +1,120 @@
+package services
+
+import (
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func resetCapabilities(t *testing.T) {
+	serverCapabilities = map[string][]string{}
+	t.Cleanup(func() { serverCapabilities = map[string][]string{} })
+}
+
+// Later requests to a server carry the parameters its first response allows
+func TestCapabilityRequestURL(t *testing.T) {
+	resetCapabilities(t)
+	recordCapabilities("https://rdap.example/domain/example.com", []byte(`{"rdapConformance":["rdap_level_0","jscontact"]}`))
+	// Capabilities a later response advertises are added to those known
+	recordCapabilities("https://rdap.example/domains?name=x*", []byte(`{"rdapConformance":["rdap_level_0","paging_level_0","sorting_level_0"]}`))
+	recordCapabilities("https://rdap.example/help", []byte(`{"rdapConformance":["rdap_level_0"]}`))
+
+	tests := []struct {
+		URL  string
+		want url.Values
+	}{
+		{"https://rdap.example/domain/example.com", url.Values{"jscard": {"1"}}},
+		{"https://rdap.example/domains?name=ex*", url.Values{"name": {"ex*"}, "jscard": {"1"}, "count": {"true"}, "sort": {"name"}}},
+		{"https://rdap.example/ips?handle=NET*&cursor=abc", url.Values{"handle": {"NET*"}, "cursor": {"abc"}, "jscard": {"1"}, "count": {"true"}}},
+		{"https://RDAP.example/entities?fn=x&sort=fn", url.Values{"fn": {"x"}, "sort": {"fn"}, "jscard": {"1"}, "count": {"true"}}},
+		{"https://other.example/domains?name=ex*", url.Values{"name": {"ex*"}}},
+	}
+	for _, test := range tests {
+		parsed, err := url.Parse(capabilityRequestURL(test.URL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parsed.Query(); got.Encode() != test.want.Encode() {
+			t.Errorf("%v: parameters %v, want %v", test.URL, got, test.want)
+		}
+	}
+}
+
+// Next page links are only followed for servers that advertised paging
+func TestNextPageURL(t *testing.T) {
+	resetCapabilities(t)
+	page := []byte(`{"rdapConformance":["paging"],"paging_metadata":{"links":[{"rel":"next","href":"https://paged.example/ips?cursor=2"}]}}`)
+
+	if next := nextPageURL("https://paged.example/ips?handle=x", page); next != "" {
+		t.Errorf("next page %q followed before the server's capabilities are known", next)
+	}
+	recordCapabilities("https://paged.example/ips?handle=x", page)
+	if next := nextPageURL("https://paged.example/ips?handle=x", page); next != "https://paged.example/ips?cursor=2" {
+		t.Errorf("next page %q", next)
+	}
+
+	recordCapabilities("https://plain.example/help", []byte(`{"rdapConformance":["rdap_level_0"]}`))
+	if next := nextPageURL("https://plain.example/ips?handle=x", page); next != "" {
+		t.Errorf("next page %q followed for a server without paging", next)
+	}
+}
+
+// Capabilities are kept on the result as the server had advertised them when
+// it was fetched, through the summary and the history database
+func TestResultCapabilities(t *testing.T) {
+	server, _ := rdapServer(t, map[string]string{
+		"/help":          `{"rdapConformance": ["rdap_level_0", "redacted", "paging"]}`,
+		"/entity/PLAIN":  `{"objectClassName": "entity", "handle": "PLAIN"}`,
+		"/entity/SORTED": `{"objectClassName": "entity", "handle": "SORTED", "rdapConformance": ["rdap_level_0", "sorting"]}`,
+	})
+	if _, _, err := queryRDAPServer(server.URL + "/help"); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := fetchObject(server.URL + "/entity/PLAIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sorted, err := fetchObject(server.URL + "/entity/SORTED")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Later responses and other runs do not change what a result recorded
+	resetCapabilities(t)
+	if !reflect.DeepEqual(plain.Capabilities, []string{"redacted", "paging"}) || !plain.Supports("redacted") || plain.Supports("sorting") {
+		t.Errorf("plain capabilities %q", plain.Capabilities)
+	}
+	if !reflect.DeepEqual(sorted.Capabilities, []string{"redacted", "paging", "sorting"}) {
+		t.Errorf("sorted capabilities %q", sorted.Capabilities)
+	}
+	if summary := Summarize(plain); !reflect.DeepEqual(summary.Capabilities, []string{"redacted", "paging"}) || summary.Row()[len(SummaryFields)-1] != "redacted;paging" {
+		t.Errorf("summary capabilities %q", summary.Capabilities)
+	}
+
+	history, err := OpenHistory(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+	if err := history.Save([]Result{plain}); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := history.Snapshots("PLAIN")
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("%v snapshots, error %v", len(snapshots), err)
+	}
+	snapshot, err := history.Snapshot(snapshots[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := snapshot.Result(); err != nil || !reflect.DeepEqual(stored.Capabilities, plain.Capabilities) {
+		t.Errorf("stored capabilities %q, error %v", stored.Capabilities, err)
+	}
+
+	// Results read back from a file only have their own rdapConformance
+	loaded := decodedResult(t, "SORTED", "entity", string(sorted.Raw))
+	if !reflect.DeepEqual(loaded.capabilities(), []string{"sorting"}) {
+		t.Errorf("loaded capabilities %q", loaded.capabilities())
+	}
+}
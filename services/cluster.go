@@ -0,0 +1,182 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Group indicators of a batch by the attributes they share
+
+// Attributes indicators can be clustered on
+var ClusterKeys = []string{"registrar", "nameservers", "registrant-org", "registrant-email", "created", "network"}
+
+// Indicators sharing one attribute value
+type Cluster struct {
+	Key        string   `json:"key"`
+	Value      string   `json:"value"`
+	Indicators []string `json:"indicators"`
+}
+
+// Group the results by each key, keeping groups of at least minSize
+// indicators, largest first. Registry and registrar results of the same
+// query both contribute, so a registrant only the registrar publishes is
+// still found. Domains are clustered on network by the networks hosting
+// their nameservers, from NameserverHosts.
+func ClusterResults(results []Result, hosts []NameserverHost, keys []string, minSize int) []Cluster {
+	var clusters []Cluster
+
+	for _, key := range keys {
+		groups := map[string][]string{}
+		var values []string
+		for _, result := range results {
+			value := clusterValue(result, hosts, key)
+			if value == "" || slices.Contains(groups[value], result.Query) {
+				continue
+			}
+			if groups[value] == nil {
+				values = append(values, value)
+			}
+			groups[value] = append(groups[value], result.Query)
+		}
+
+		for _, value := range values {
+			if len(groups[value]) >= minSize {
+				clusters = append(clusters, Cluster{Key: key, Value: value, Indicators: groups[value]})
+			}
+		}
+	}
+
+	slices.SortStableFunc(clusters, func(a Cluster, b Cluster) int {
+		return len(b.Indicators) - len(a.Indicators)
+	})
+	return clusters
+}
+
+// Extract the value of a cluster key from a result, empty when missing or
+// redacted, either by the server's redacted member or by a placeholder value
+func clusterValue(result Result, hosts []NameserverHost, key string) string {
+	summary := Summarize(result)
+
+	var value string
+	switch key {
+	case "registrar":
+		// By IANA ID where published, registries and registrars spell names differently
+		value = summary.Registrar
+		if summary.RegistrarIANAID != "" {
+			value = "IANA Registrar ID " + summary.RegistrarIANAID
+		}
+	case "nameservers":
+		nameservers := slices.Clone(summary.Nameservers)
+		for i, nameserver := range nameservers {
+			nameservers[i] = strings.TrimSuffix(nameserver, ".")
+		}
+		slices.Sort(nameservers)
+		value = strings.Join(slices.Compact(nameservers), ", ")
+	case "registrant-org":
+		// Placeholders of fields the server declares redacted are not shared values
+		if !slices.Contains(redactedFields(result), "registrant organization") {
+			value = summary.RegistrantOrg
+		}
+	case "registrant-email":
+		if result.Domain != nil && !slices.Contains(redactedFields(result), "registrant email") {
+			if registrant := findEntity(result.Domain.Entities, "registrant"); registrant != nil {
+				value = hashEmail(vcardText(*registrant, "email"))
+			}
+		}
+	case "created":
+		if date, err := (m.Events{EventDate: summary.Created}).Date(); err == nil {
+			value = date.UTC().Format("2006-01-02")
+		}
+	case "network":
+		if result.Domain != nil {
+			value = nameserverNetworks(result, hosts)
+			if value == "" && result.Domain.Network != nil {
+				value = firstNonEmpty(result.Domain.Network.Handle, result.Domain.Network.StartAddress)
+			}
+		} else if result.IPNetwork != nil {
+			value = firstNonEmpty(strings.Join(summary.NetworkCIDR, ", "), result.IPNetwork.Name, result.IPNetwork.Handle)
+		}
+	}
+
+	if isRedacted(value) {
+		return ""
+	}
+	return value
+}
+
+// The networks hosting a domain's nameservers, as one sorted list so domains
+// cluster on the same set like they do on nameservers. Networks are named by
+// CIDR, like IP address results, so an address clusters with the domains
+// whose DNS it hosts.
+func nameserverNetworks(result Result, hosts []NameserverHost) string {
+	domain := strings.ToLower(firstNonEmpty(result.Domain.LdhName, result.Query))
+	var networks []string
+	for _, host := range hosts {
+		if host.Domain == domain {
+			if network := firstNonEmpty(strings.Join(host.CIDR, ", "), host.Network); network != "" {
+				networks = append(networks, network)
+			}
+		}
+	}
+	slices.Sort(networks)
+	return strings.Join(slices.Compact(networks), "; ")
+}
+
+// SHA-256 of the lower-cased address, so clusters can be shared without the
+// address itself
+func hashEmail(email string) string {
+	email = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(email), "mailto:"))
+	if email == "" || isRedacted(email) {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(email))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Values registries publish in place of withheld contact data
+func isRedacted(value string) bool {
+	value = strings.ToLower(value)
+	for _, marker := range []string{"redacted", "data protected", "withheld", "not disclosed", "privacy"} {
+		if strings.Contains(value, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Print clusters with their evidence
+func PrintClusters(w io.Writer, clusters []Cluster) {
+	fmt.Fprintf(w, "\nRDAP Clusters")
+	fmt.Fprintf(w, "\n---------------------------------------------------------------")
+	if len(clusters) == 0 {
+		fmt.Fprintf(w, "\n\n(=) No shared attributes\n")
+		return
+	}
+	for _, cluster := range clusters {
+		fmt.Fprintf(w, "\n\n%v indicators share %v:\t%v", len(cluster.Indicators), cluster.Key, cluster.Value)
+		for _, indicator := range cluster.Indicators {
+			fmt.Fprintf(w, "\n\t%v", indicator)
+		}
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// Write clusters as a JSON array
+func WriteClustersJSON(w io.Writer, clusters []Cluster) error {
+	if clusters == nil {
+		clusters = []Cluster{}
+	}
+	output, err := json.MarshalIndent(clusters, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(output, '\n'))
+	return err
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// A domain response with a registrar, registrant, creation date and
+// nameservers with glue addresses
+func clusterDomain(t *testing.T, name string, registrar string, ianaID string, org string, email string, created string, nameservers string) Result {
+	t.Helper()
+	raw := fmt.Sprintf(`{
+		"objectClassName": "domain",
+		"ldhName": %q,
+		"rdapConformance": ["rdap_level_0", "redacted"],
+		"events": [{"eventAction": "registration", "eventDate": %q}],
+		"nameservers": [%v],
+		"entities": [
+			{"objectClassName": "entity", "roles": ["registrar"], "publicIds": [{"type": "IANA Registrar ID", "identifier": %q}],
+				"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", %q]]]},
+			{"objectClassName": "entity", "roles": ["registrant"],
+				"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", ""], ["org", {}, "text", %q], ["email", {}, "text", %q]]]}
+		]
+	}`, name, created, nameservers, ianaID, registrar, org, email)
+	return decodedResult(t, name, "domain", raw)
+}
+
+func glue(name string, v4 string, v6 string) string {
+	return fmt.Sprintf(`{"objectClassName": "nameserver", "ldhName": %q, "ipAddresses": {"v4": [%v], "v6": [%v]}}`, name, v4, v6)
+}
+
+func TestClusterResults(t *testing.T) {
+	shared := glue("ns1.host.example", `"192.0.2.53"`, "") + "," + glue("ns2.host.example", "", `"2001:db8::53"`)
+	// The same nameservers, spelled differently and with the IPv4 glue
+	// address published IPv4-mapped
+	sharedMapped := glue("NS2.HOST.EXAMPLE.", "", `"2001:db8::53"`) + "," + glue("ns1.host.example.", "", `"::ffff:192.0.2.53"`)
+	other := glue("ns.other.example", `"198.51.100.53"`, "")
+
+	a := clusterDomain(t, "a.example", "Example Registrar, Inc.", "292", "Acme", "Hostmaster@Acme.example", "2026-01-10T01:00:00Z", shared)
+	b := clusterDomain(t, "b.example", "EXAMPLE REGISTRAR INC", "292", "Acme", "mailto:hostmaster@acme.example", "2026-01-10T23:00:00Z", sharedMapped)
+	c := clusterDomain(t, "c.example", "Example Registrar, Inc.", "", "REDACTED FOR PRIVACY", "", "2025-05-01T00:00:00Z", other)
+
+	// A registrar response of a.example adds nothing a.example already has
+	registrarA := a
+	registrarA.ServerURL = "https://registrar.example/domain/a.example"
+
+	// The network hosting the shared nameservers, looked up for the first
+	// address of each, and an address query inside it
+	network := func(query string) Result {
+		return decodedResult(t, query, "ip network", `{"objectClassName": "ip network", "handle": "NET-192-0-2-0-1", "name": "HOSTING-NET", "startAddress": "192.0.2.0", "endAddress": "192.0.2.255"}`)
+	}
+	network6 := decodedResult(t, "2001:db8::53", "ip network", `{"objectClassName": "ip network", "handle": "NET6-2001-DB8-1", "startAddress": "2001:db8::", "endAddress": "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"}`)
+	address := network("192.0.2.1")
+
+	results := []Result{a, registrarA, b, c, address}
+	hosts := NameserverHosts([]Result{a, b, c, network("192.0.2.53"), network6})
+
+	hash := sha256.Sum256([]byte("hostmaster@acme.example"))
+	emailHash := "sha256:" + hex.EncodeToString(hash[:])
+
+	tests := []struct {
+		key  string
+		want []Cluster
+	}{
+		{key: "registrar", want: []Cluster{{Key: "registrar", Value: "IANA Registrar ID 292", Indicators: []string{"a.example", "b.example"}}}},
+		{key: "nameservers", want: []Cluster{{Key: "nameservers", Value: "ns1.host.example, ns2.host.example", Indicators: []string{"a.example", "b.example"}}}},
+		{key: "registrant-org", want: []Cluster{{Key: "registrant-org", Value: "Acme", Indicators: []string{"a.example", "b.example"}}}},
+		{key: "registrant-email", want: []Cluster{{Key: "registrant-email", Value: emailHash, Indicators: []string{"a.example", "b.example"}}}},
+		{key: "created", want: []Cluster{{Key: "created", Value: "2026-01-10", Indicators: []string{"a.example", "b.example"}}}},
+		{key: "network", want: []Cluster{{Key: "network", Value: "192.0.2.0/24; 2001:db8::/32", Indicators: []string{"a.example", "b.example"}}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			if clusters := ClusterResults(results, hosts, []string{test.key}, 2); !reflect.DeepEqual(clusters, test.want) {
+				t.Errorf("got %+v, want %+v", clusters, test.want)
+			}
+		})
+	}
+
+	// Singletons are kept at a minimum size of one, and larger groups sort
+	// first. c.example has no cluster on network, its nameserver address was
+	// not looked up.
+	clusters := ClusterResults(results, hosts, []string{"created", "network"}, 1)
+	var values []string
+	for _, cluster := range clusters {
+		values = append(values, cluster.Key+" "+cluster.Value)
+	}
+	want := []string{"created 2026-01-10", "network 192.0.2.0/24; 2001:db8::/32", "created 2025-05-01", "network 192.0.2.0/24"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %q, want %q", values, want)
+	}
+}
+
+// Placeholders are not shared values, whether or not the server declares the
+// field redacted
+func TestClusterRedacted(t *testing.T) {
+	redacted := func(name string) Result {
+		raw := fmt.Sprintf(`{
+			"objectClassName": "domain",
+			"ldhName": %q,
+			"rdapConformance": ["rdap_level_0", "redacted"],
+			"redacted": [{"name": {"type": "Registrant Email"}}, {"name": {"type": "Registrant Organization"}}],
+			"entities": [{"objectClassName": "entity", "roles": ["registrant"],
+				"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["org", {}, "text", "Acme"], ["email", {}, "text", "shared@example.com"]]]}]
+		}`, name)
+		return decodedResult(t, name, "domain", raw)
+	}
+	placeholder := clusterDomain(t, "c.example", "", "", "Data Protected", "REDACTED@privacy.example", "", "")
+	placeholder2 := clusterDomain(t, "d.example", "", "", "Data Protected", "REDACTED@privacy.example", "", "")
+
+	results := []Result{redacted("a.example"), redacted("b.example"), placeholder, placeholder2}
+	if clusters := ClusterResults(results, nil, []string{"registrant-org", "registrant-email"}, 2); len(clusters) != 0 {
+		t.Errorf("clustered on redacted values: %+v", clusters)
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Compare the registry's domain object with the registrar's. Differences
+// usually mean one side is stale, or a transfer or hijack is in progress.
+
+// Report the differences between the registry and registrar objects of a
+// domain as changes from the registry value (Old) to the registrar value (New)
+func CheckConsistency(registry m.Domain, registrar m.Domain) []Change {
+	var changes []Change
+
+	registryNameservers, registrarNameservers := nameserverNames(registry.Nameservers), nameserverNames(registrar.Nameservers)
+	slices.Sort(registryNameservers)
+	slices.Sort(registrarNameservers)
+	if len(registrarNameservers) > 0 && !slices.Equal(registryNameservers, registrarNameservers) {
+		changes = append(changes, Change{Field: "nameservers", Change: "differs", Old: strings.Join(registryNameservers, ", "), New: strings.Join(registrarNameservers, ", ")})
+	}
+
+	// Registrars often publish EPP status codes, registries RDAP values
+	registryStatuses, registrarStatuses := normalizeStatuses(registry.Status), normalizeStatuses(registrar.Status)
+	if len(registrarStatuses) > 0 && !slices.Equal(registryStatuses, registrarStatuses) {
+		changes = append(changes, Change{Field: "status", Change: "differs", Old: strings.Join(registry.Status, ", "), New: strings.Join(registrar.Status, ", ")})
+	}
+
+	// Registrars commonly store a different time of day, only the date matters
+	registryExpires, registryFound := eventTime(registry.Events, "expiration")
+	registrarExpires, registrarFound := eventTime(registrar.Events, "expiration")
+	if registryFound && registrarFound && registryExpires.UTC().Format("2006-01-02") != registrarExpires.UTC().Format("2006-01-02") {
+		changes = append(changes, Change{Field: "events.expiration", Change: "differs", Old: eventDate(registry.Events, "expiration"), New: eventDate(registrar.Events, "expiration")})
+	}
+
+	if registrar.SecureDNS.DelegationSigned != nil && boolString(registry.SecureDNS.DelegationSigned) != boolString(registrar.SecureDNS.DelegationSigned) {
+		changes = append(changes, Change{Field: "secureDNS.delegationSigned", Change: "differs", Old: boolString(registry.SecureDNS.DelegationSigned), New: boolString(registrar.SecureDNS.DelegationSigned)})
+	}
+
+	// Registrar identity by IANA ID when both publish one, by name otherwise
+	registryEntity, registrarEntity := findEntity(registry.Entities, "registrar"), findEntity(registrar.Entities, "registrar")
+	if registryEntity != nil && registrarEntity != nil {
+		registryID, registrarID := ianaRegistrarID(*registryEntity), ianaRegistrarID(*registrarEntity)
+		if registryID != "" && registrarID != "" {
+			if registryID != registrarID {
+				changes = append(changes, Change{Field: "entities.registrar.ianaId", Change: "differs", Old: registryID, New: registrarID})
+			}
+		} else if !strings.EqualFold(entityName(*registryEntity), entityName(*registrarEntity)) {
+			changes = append(changes, Change{Field: "entities.registrar.name", Change: "differs", Old: entityName(*registryEntity), New: entityName(*registrarEntity)})
+		}
+	}
+
+	return changes
+}
+
+func normalizeStatuses(statuses []string) []string {
+	var normalized []string
+	for _, status := range statuses {
+		normalized = append(normalized, normalizeStatus(status))
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+func ianaRegistrarID(entity m.Entity) string {
+	for _, publicId := range entity.PublicIds {
+		if publicId.Type == "IANA Registrar ID" {
+			return publicId.Identifier
+		}
+	}
+	return ""
+}
+
+// Print the consistency check of a registry and registrar result
+func printConsistency(w io.Writer, registry Result, registrar Result) {
+	changes := CheckConsistency(*registry.Domain, *registrar.Domain)
+
+	fmt.Fprintf(w, "\n\nRegistry/Registrar Consistency")
+	fmt.Fprintf(w, "\n---------------------------------------------------------------")
+	fmt.Fprintf(w, "\nRegistry:\t%v", registry.ServerURL)
+	fmt.Fprintf(w, "\nRegistrar:\t%v", registrar.ServerURL)
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "\n\n(=) Consistent")
+		return
+	}
+	fmt.Fprintf(w, "\n\n(!) %v discrepancies, registry -> registrar:", len(changes))
+	printChangeLines(w, changes)
+}
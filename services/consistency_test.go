@@ -0,0 +1,83 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+const consistencyRegistry = `{
+	"status": ["client transfer prohibited", "server delete prohibited"],
+	"nameservers": [{"ldhName": "a.iana-servers.net"}, {"ldhName": "b.iana-servers.net"}],
+	"events": [{"eventAction": "expiration", "eventDate": "2026-08-13T04:00:00Z"}],
+	"secureDNS": {"delegationSigned": true},
+	"entities": [{"roles": ["registrar"], "publicIds": [{"type": "IANA Registrar ID", "identifier": "376"}],
+		"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "RESERVED-Internet Assigned Numbers Authority"]]]}]
+}`
+
+func TestCheckConsistency(t *testing.T) {
+	tests := []struct {
+		name      string
+		registrar string
+		want      []Change
+	}{
+		{
+			name: "consistent despite spelling, order and time of day",
+			registrar: `{
+				"status": ["serverDeleteProhibited", "clientTransferProhibited"],
+				"nameservers": [{"ldhName": "B.IANA-SERVERS.NET."}, {"ldhName": "A.iana-servers.net"}],
+				"events": [{"eventAction": "expiration", "eventDate": "2026-08-13T23:59:59Z"}],
+				"secureDNS": {"delegationSigned": true},
+				"entities": [{"roles": ["registrar"], "publicIds": [{"type": "IANA Registrar ID", "identifier": "376"}],
+					"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "IANA"]]]}]
+			}`,
+		},
+		{
+			name:      "registrar publishing nothing",
+			registrar: `{}`,
+		},
+		{
+			name:      "nameservers differ",
+			registrar: `{"nameservers": [{"ldhName": "a.iana-servers.net."}, {"ldhName": "ns1.attacker.example"}]}`,
+			want: []Change{{Field: "nameservers", Change: "differs",
+				Old: "a.iana-servers.net, b.iana-servers.net", New: "a.iana-servers.net, ns1.attacker.example"}},
+		},
+		{
+			name:      "statuses differ",
+			registrar: `{"status": ["clientTransferProhibited"]}`,
+			want: []Change{{Field: "status", Change: "differs",
+				Old: "client transfer prohibited, server delete prohibited", New: "clientTransferProhibited"}},
+		},
+		{
+			name:      "expiration dates differ",
+			registrar: `{"events": [{"eventAction": "expiration", "eventDate": "2027-08-13T04:00:00Z"}]}`,
+			want: []Change{{Field: "events.expiration", Change: "differs",
+				Old: "2026-08-13T04:00:00Z", New: "2027-08-13T04:00:00Z"}},
+		},
+		{
+			name:      "delegation signed differs",
+			registrar: `{"secureDNS": {"delegationSigned": false}}`,
+			want:      []Change{{Field: "secureDNS.delegationSigned", Change: "differs", Old: "true", New: "false"}},
+		},
+		{
+			name:      "registrar IANA ID differs",
+			registrar: `{"entities": [{"roles": ["registrar"], "publicIds": [{"type": "IANA Registrar ID", "identifier": "292"}]}]}`,
+			want:      []Change{{Field: "entities.registrar.ianaId", Change: "differs", Old: "376", New: "292"}},
+		},
+		{
+			name: "registrar name compared without an IANA ID",
+			registrar: `{"entities": [{"roles": ["registrar"],
+				"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Other Registrar"]]]}]}`,
+			want: []Change{{Field: "entities.registrar.name", Change: "differs",
+				Old: "RESERVED-Internet Assigned Numbers Authority", New: "Other Registrar"}},
+		},
+	}
+
+	registry := riskDomain(t, consistencyRegistry)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if changes := CheckConsistency(registry, riskDomain(t, test.registrar)); !reflect.DeepEqual(changes, test.want) {
+				t.Errorf("got %+v, want %+v", changes, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Field level differences between two lookups of the same object
+
+// One difference between two results
+type Change struct {
+	Field  string `json:"field"`
+	Change string `json:"change"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// Compare two results of the same object type. A response only one side has
+// is reported as an added or removed response.
+func DiffResults(old Result, new Result) []Change {
+	var changes []Change
+
+	if old.object() == nil && new.object() != nil {
+		changes = append(changes, Change{Field: "response", Change: "added", New: new.ServerURL})
+	} else if new.object() == nil && old.object() != nil {
+		changes = append(changes, Change{Field: "response", Change: "removed", Old: old.ServerURL})
+	} else if old.Domain != nil && new.Domain != nil {
+		changes = diffDomains(*old.Domain, *new.Domain)
+	} else if old.IPNetwork != nil && new.IPNetwork != nil {
+		changes = diffNetworks(*old.IPNetwork, *new.IPNetwork)
+	} else if old.Autonum != nil && new.Autonum != nil {
+		changes = diffAutonums(*old.Autonum, *new.Autonum)
+	} else {
+		changes = diffValue(changes, "objectClassName", objectClassName(old), objectClassName(new))
+	}
+
+	return changes
+}
+
+// Two responses for the same object, either of which may be missing
+type ResultPair struct {
+	Old Result
+	New Result
+}
+
+// Pair the responses of two lookups by query and server, then by server alone,
+// then by object class in order for responses whose server changed. Responses left over on either side are
+// paired with an empty result.
+func PairResults(old []Result, new []Result) []ResultPair {
+	pairs := make([]ResultPair, len(old))
+	paired := make([]bool, len(old))
+	used := make([]bool, len(new))
+	pair := func(match func(old Result, new Result) bool) {
+		for i := range old {
+			for j := range new {
+				if !paired[i] && !used[j] && match(old[i], new[j]) {
+					pairs[i] = ResultPair{Old: old[i], New: new[j]}
+					paired[i], used[j] = true, true
+				}
+			}
+		}
+	}
+	pair(func(old Result, new Result) bool { return old.Query == new.Query && old.ServerURL == new.ServerURL })
+	pair(func(old Result, new Result) bool { return old.ServerURL == new.ServerURL })
+	pair(func(old Result, new Result) bool { return objectClassName(old) == objectClassName(new) })
+
+	for i := range old {
+		if !paired[i] {
+			pairs[i] = ResultPair{Old: old[i]}
+		}
+	}
+	for j := range new {
+		if !used[j] {
+			pairs = append(pairs, ResultPair{New: new[j]})
+		}
+	}
+	return pairs
+}
+
+func diffDomains(old m.Domain, new m.Domain) []Change {
+	var changes []Change
+
+	changes = diffValue(changes, "handle", old.Handle, new.Handle)
+	changes = diffValue(changes, "ldhName", strings.ToLower(old.LdhName), strings.ToLower(new.LdhName))
+	changes = diffValue(changes, "unicodeName", old.UnicodeName, new.UnicodeName)
+	changes = diffSet(changes, "nameservers", nameserverNames(old.Nameservers), nameserverNames(new.Nameservers))
+	changes = diffSet(changes, "status", old.Status, new.Status)
+	changes = diffValue(changes, "secureDNS.delegationSigned", boolString(old.SecureDNS.DelegationSigned), boolString(new.SecureDNS.DelegationSigned))
+	changes = diffSet(changes, "secureDNS.dsData", dsRecords(old.SecureDNS.DSData), dsRecords(new.SecureDNS.DSData))
+	changes = diffValue(changes, "port43", old.Port43, new.Port43)
+	changes = diffEvents(changes, "events", old.Events, new.Events)
+	changes = diffEntities(changes, old.Entities, new.Entities)
+
+	return changes
+}
+
+func diffNetworks(old m.IPNetwork, new m.IPNetwork) []Change {
+	var changes []Change
+
+	changes = diffValue(changes, "handle", old.Handle, new.Handle)
+	changes = diffValue(changes, "name", old.Name, new.Name)
+	changes = diffValue(changes, "type", old.Type, new.Type)
+	changes = diffValue(changes, "startAddress", old.StartAddress, new.StartAddress)
+	changes = diffValue(changes, "endAddress", old.EndAddress, new.EndAddress)
+	changes = diffValue(changes, "country", old.Country, new.Country)
+	changes = diffValue(changes, "parentHandle", old.ParentHandle, new.ParentHandle)
+	changes = diffSet(changes, "status", old.Status, new.Status)
+	changes = diffValue(changes, "port43", old.Port43, new.Port43)
+	changes = diffEvents(changes, "events", old.Events, new.Events)
+	changes = diffEntities(changes, old.Entities, new.Entities)
+
+	return changes
+}
+
+func diffAutonums(old m.Autonum, new m.Autonum) []Change {
+	var changes []Change
+
+	changes = diffValue(changes, "handle", old.Handle, new.Handle)
+	changes = diffValue(changes, "name", old.Name, new.Name)
+	changes = diffValue(changes, "type", old.Type, new.Type)
+	changes = diffValue(changes, "startAutnum", strconv.FormatUint(uint64(old.StartAutnum), 10), strconv.FormatUint(uint64(new.StartAutnum), 10))
+	changes = diffValue(changes, "endAutnum", strconv.FormatUint(uint64(old.EndAutnum), 10), strconv.FormatUint(uint64(new.EndAutnum), 10))
+	changes = diffValue(changes, "country", old.Country, new.Country)
+	changes = diffSet(changes, "status", old.Status, new.Status)
+	changes = diffEvents(changes, "events", old.Events, new.Events)
+	changes = diffEntities(changes, old.Entities, new.Entities)
+
+	return changes
+}
+
+// Record a change to a single value
+func diffValue(changes []Change, field string, old string, new string) []Change {
+	switch {
+	case old == new:
+	case old == "":
+		changes = append(changes, Change{Field: field, Change: "added", New: new})
+	case new == "":
+		changes = append(changes, Change{Field: field, Change: "removed", Old: old})
+	default:
+		changes = append(changes, Change{Field: field, Change: "changed", Old: old, New: new})
+	}
+	return changes
+}
+
+// Record members added to or removed from a set
+func diffSet(changes []Change, field string, old []string, new []string) []Change {
+	for _, value := range old {
+		if !slices.Contains(new, value) {
+			changes = append(changes, Change{Field: field, Change: "removed", Old: value})
+		}
+	}
+	for _, value := range new {
+		if !slices.Contains(old, value) {
+			changes = append(changes, Change{Field: field, Change: "added", New: value})
+		}
+	}
+	return changes
+}
+
+// Compare event dates by action. "last update of RDAP database" changes on
+// every query so it is ignored.
+func diffEvents(changes []Change, field string, old []m.Events, new []m.Events) []Change {
+	oldDates := eventDates(old)
+	newDates := eventDates(new)
+
+	var actions []string
+	for _, events := range [][]m.Events{old, new} {
+		for _, event := range events {
+			if !slices.Contains(actions, event.EventAction) && event.EventAction != "last update of RDAP database" {
+				actions = append(actions, event.EventAction)
+			}
+		}
+	}
+
+	for _, action := range actions {
+		changes = diffValue(changes, field+"."+action, oldDates[action], newDates[action])
+	}
+	return changes
+}
+
+// Compare the entity holding each role
+func diffEntities(changes []Change, old []m.Entity, new []m.Entity) []Change {
+	var roles []string
+	for _, entities := range [][]m.Entity{old, new} {
+		roles = appendRoles(roles, entities)
+	}
+
+	for _, role := range roles {
+		var oldEntity, newEntity m.Entity
+		if entity := findEntity(old, role); entity != nil {
+			oldEntity = *entity
+		}
+		if entity := findEntity(new, role); entity != nil {
+			newEntity = *entity
+		}
+
+		field := "entities." + role
+		changes = diffValue(changes, field+".handle", oldEntity.Handle, newEntity.Handle)
+		changes = diffValue(changes, field+".name", entityName(oldEntity), entityName(newEntity))
+		changes = diffValue(changes, field+".email", vcardText(oldEntity, "email"), vcardText(newEntity, "email"))
+		changes = diffValue(changes, field+".country", entityCountry(oldEntity), entityCountry(newEntity))
+	}
+	return changes
+}
+
+// Collect the roles of entities and their nested entities
+func appendRoles(roles []string, entities []m.Entity) []string {
+	for _, entity := range entities {
+		for _, role := range entity.Roles {
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+		roles = appendRoles(roles, entity.Entities)
+	}
+	return roles
+}
+
+// Map event actions to their dates
+func eventDates(events []m.Events) map[string]string {
+	dates := map[string]string{}
+	for _, event := range events {
+		if dates[event.EventAction] != "" {
+			dates[event.EventAction] += ", "
+		}
+		dates[event.EventAction] += event.EventDate
+	}
+	return dates
+}
+
+func nameserverNames(nameservers []m.Nameserver) []string {
+	var names []string
+	for _, nameserver := range nameservers {
+		names = append(names, strings.ToLower(strings.TrimSuffix(nameserver.LdhName, ".")))
+	}
+	return names
+}
+
+func dsRecords(dsData []m.DSData) []string {
+	var records []string
+	for _, ds := range dsData {
+		records = append(records, fmt.Sprintf("%v %v %v %v", ds.KeyTag, ds.Algorithm, ds.DigestType, strings.ToUpper(ds.Digest)))
+	}
+	return records
+}
+
+func boolString(value *bool) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*value)
+}
+
+func objectClassName(result Result) string {
+	if result.Domain != nil {
+		return result.Domain.ObjectClassName
+	} else if result.IPNetwork != nil {
+		return result.IPNetwork.ObjectClassName
+	} else if result.Autonum != nil {
+		return result.Autonum.ObjectClassName
+	} else if result.Entity != nil {
+		return result.Entity.ObjectClassName
+	} else if result.Nameserver != nil {
+		return result.Nameserver.ObjectClassName
+	}
+	return ""
+}
+
+// Print changes grouped by field
+func PrintChanges(w io.Writer, old Result, new Result, changes []Change) {
+	fmt.Fprintf(w, "\nRDAP Diff")
+	fmt.Fprintf(w, "\n---------------------------------------------------------------")
+	fmt.Fprintf(w, "\nOld:\t%v\t%v", old.ServerURL, formatFetchedAt(old))
+	fmt.Fprintf(w, "\nNew:\t%v\t%v", new.ServerURL, formatFetchedAt(new))
+
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "\n\n(=) No changes\n")
+		return
+	}
+
+	fmt.Fprintf(w, "\n")
+	printChangeLines(w, changes)
+	fmt.Fprintf(w, "\n")
+}
+
+// Print one line per change, marked + added, - removed or ~ changed
+func printChangeLines(w io.Writer, changes []Change) {
+	for _, change := range changes {
+		switch change.Change {
+		case "added":
+			fmt.Fprintf(w, "\n\t+ %v:\t%v", change.Field, change.New)
+		case "removed":
+			fmt.Fprintf(w, "\n\t- %v:\t%v", change.Field, change.Old)
+		default:
+			fmt.Fprintf(w, "\n\t~ %v:\t%v -> %v", change.Field, change.Old, change.New)
+		}
+	}
+}
+
+func formatFetchedAt(result Result) string {
+	if result.FetchedAt.IsZero() {
+		return ""
+	}
+	return result.FetchedAt.Format("2006-01-02 15:04:05 MST")
+}
+
+// Load the results saved in a JSON output file, either a single object or an
+// array of objects
+func LoadResults(location string) ([]Result, error) {
+	var results []Result
+
+	data, err := os.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []json.RawMessage
+	if err := json.Unmarshal(data, &objects); err != nil {
+		objects = []json.RawMessage{data}
+	}
+
+	for _, object := range objects {
+		var header struct {
+			ObjectClassName string    `json:"objectClassName"`
+			LdhName         string    `json:"ldhName"`
+			Handle          string    `json:"handle"`
+			Links           []m.Links `json:"links"`
+		}
+		if err := json.Unmarshal(object, &header); err != nil {
+			return nil, fmt.Errorf("%v: %v", location, err)
+		}
+
+		result := Result{Query: firstNonEmpty(header.LdhName, header.Handle), ServerURL: firstNonEmpty(findLink(header.Links, "self"), location), Raw: object}
+		if err := result.decode(header.ObjectClassName); err != nil {
+			return nil, fmt.Errorf("%v: %v", location, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const diffOldDomain = `{
+	"objectClassName": "domain",
+	"handle": "D1",
+	"ldhName": "example.com",
+	"port43": "whois.example",
+	"status": ["active", "clientTransferProhibited"],
+	"nameservers": [
+		{"objectClassName": "nameserver", "ldhName": "a.iana-servers.net"},
+		{"objectClassName": "nameserver", "ldhName": "b.iana-servers.net"}
+	]
+}`
+
+const diffNewDomain = `{
+	"objectClassName": "domain",
+	"handle": "D1",
+	"ldhName": "EXAMPLE.COM",
+	"port43": "whois.example.net",
+	"status": ["active", "clientHold"],
+	"nameservers": [
+		{"objectClassName": "nameserver", "ldhName": "A.IANA-SERVERS.NET."},
+		{"objectClassName": "nameserver", "ldhName": "c.iana-servers.net"}
+	]
+}`
+
+func TestDiffResults(t *testing.T) {
+	oldDomain := decodedResult(t, "example.com", "domain", diffOldDomain)
+	newDomain := decodedResult(t, "example.com", "domain", diffNewDomain)
+	network := decodedResult(t, "192.0.2.1", "ip network", renderNetwork)
+	renamed := decodedResult(t, "192.0.2.1", "ip network", `{"objectClassName": "ip network", "handle": "NET-192-0-2-0-1", "name": "EXAMPLE-NET", "startAddress": "192.0.2.0", "endAddress": "192.0.2.255"}`)
+
+	tests := []struct {
+		name string
+		old  Result
+		new  Result
+		want []Change
+	}{
+		{
+			name: "unchanged",
+			old:  oldDomain,
+			new:  oldDomain,
+		},
+		{
+			name: "scalar fields, nameservers and statuses",
+			old:  oldDomain,
+			new:  newDomain,
+			want: []Change{
+				{Field: "nameservers", Change: "removed", Old: "b.iana-servers.net"},
+				{Field: "nameservers", Change: "added", New: "c.iana-servers.net"},
+				{Field: "status", Change: "removed", Old: "clientTransferProhibited"},
+				{Field: "status", Change: "added", New: "clientHold"},
+				{Field: "port43", Change: "changed", Old: "whois.example", New: "whois.example.net"},
+			},
+		},
+		{
+			name: "added scalar field",
+			old:  network,
+			new:  renamed,
+			want: []Change{{Field: "name", Change: "added", New: "EXAMPLE-NET"}},
+		},
+		{
+			name: "response only in the new results",
+			old:  Result{},
+			new:  network,
+			want: []Change{{Field: "response", Change: "added", New: network.ServerURL}},
+		},
+		{
+			name: "response only in the old results",
+			old:  network,
+			new:  Result{},
+			want: []Change{{Field: "response", Change: "removed", Old: network.ServerURL}},
+		},
+		{
+			name: "object class changed",
+			old:  oldDomain,
+			new:  network,
+			want: []Change{{Field: "objectClassName", Change: "changed", Old: "domain", New: "ip network"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if changes := DiffResults(test.old, test.new); !reflect.DeepEqual(changes, test.want) {
+				t.Errorf("got %+v, want %+v", changes, test.want)
+			}
+		})
+	}
+}
+
+func TestPairResults(t *testing.T) {
+	result := func(query string, serverURL string, raw string) Result {
+		result := decodedResult(t, query, "ip network", raw)
+		result.ServerURL = serverURL
+		return result
+	}
+	bootstrap := result("192.0.2.1", "https://rdap.example/ip/192.0.2.1", renderNetwork)
+	referral := result("192.0.2.1", "https://rdap.example/ip/192.0.2.0/24", renderNetwork)
+	other := result("192.0.2.9", "https://rdap.example/ip/192.0.2.1", renderNetwork)
+	moved := result("192.0.2.1", "https://rdap.other.example/ip/192.0.2.1", renderNetwork)
+	domain := decodedResult(t, "example.com", "domain", diffOldDomain)
+
+	tests := []struct {
+		name string
+		old  []Result
+		new  []Result
+		want []ResultPair
+	}{
+		{
+			name: "same query and server in a different order",
+			old:  []Result{bootstrap, referral},
+			new:  []Result{referral, bootstrap},
+			want: []ResultPair{{Old: bootstrap, New: bootstrap}, {Old: referral, New: referral}},
+		},
+		{
+			name: "query preferred over a shared server",
+			old:  []Result{other, bootstrap},
+			new:  []Result{bootstrap, other},
+			want: []ResultPair{{Old: other, New: other}, {Old: bootstrap, New: bootstrap}},
+		},
+		{
+			name: "server changed",
+			old:  []Result{bootstrap},
+			new:  []Result{moved},
+			want: []ResultPair{{Old: bootstrap, New: moved}},
+		},
+		{
+			name: "responses on one side only",
+			old:  []Result{bootstrap, domain},
+			new:  []Result{bootstrap},
+			want: []ResultPair{{Old: bootstrap, New: bootstrap}, {Old: domain}},
+		},
+		{
+			name: "new responses appended",
+			old:  nil,
+			new:  []Result{domain},
+			want: []ResultPair{{New: domain}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pairs := PairResults(test.old, test.new)
+			if len(pairs) != len(test.want) {
+				t.Fatalf("got %v pairs, want %v", len(pairs), len(test.want))
+			}
+			for i := range pairs {
+				got, want := pairs[i], test.want[i]
+				if got.Old.Query != want.Old.Query || got.Old.ServerURL != want.Old.ServerURL ||
+					got.New.Query != want.New.Query || got.New.ServerURL != want.New.ServerURL {
+					t.Errorf("pair %v: got %v %v -> %v %v, want %v %v -> %v %v", i,
+						got.Old.Query, got.Old.ServerURL, got.New.Query, got.New.ServerURL,
+						want.Old.Query, want.Old.ServerURL, want.New.Query, want.New.ServerURL)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadResults(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data string) string {
+		location := filepath.Join(dir, name)
+		if err := os.WriteFile(location, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return location
+	}
+	linkedDomain := `{"objectClassName": "domain", "ldhName": "example.com", "links": [{"rel": "self", "href": "https://rdap.example/domain/example.com"}]}`
+
+	single := write("single.json", linkedDomain)
+	array := write("array.json", "["+linkedDomain+","+renderNetwork+"]")
+
+	tests := []struct {
+		name     string
+		location string
+		want     [][3]string
+	}{
+		{
+			name:     "single object",
+			location: single,
+			want:     [][3]string{{"example.com", "https://rdap.example/domain/example.com", "domain"}},
+		},
+		{
+			name:     "array of objects",
+			location: array,
+			want: [][3]string{
+				{"example.com", "https://rdap.example/domain/example.com", "domain"},
+				{"NET-192-0-2-0-1", array, "ip network"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results, err := LoadResults(test.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][3]string
+			for _, result := range results {
+				got = append(got, [3]string{result.Query, result.ServerURL, objectClassName(result)})
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	if _, err := LoadResults(write("invalid.json", `{"objectClassName": 1}`)); err == nil {
+		t.Error("expected an error for an invalid object")
+	}
+	if _, err := LoadResults(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
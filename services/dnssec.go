@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// DNSSEC delegation data of domains
+
+// DNS Security Algorithm Numbers
+// https://www.iana.org/assignments/dns-sec-alg-numbers/dns-sec-alg-numbers.xhtml
+var dnssecAlgorithms = map[int]string{
+	1:  "RSAMD5",
+	3:  "DSA",
+	5:  "RSASHA1",
+	6:  "DSA-NSEC3-SHA1",
+	7:  "RSASHA1-NSEC3-SHA1",
+	8:  "RSASHA256",
+	10: "RSASHA512",
+	12: "ECC-GOST",
+	13: "ECDSAP256SHA256",
+	14: "ECDSAP384SHA384",
+	15: "ED25519",
+	16: "ED448",
+	17: "SM2SM3",
+	23: "ECC-GOST12",
+}
+
+// Delegation Signer (DS) Resource Record Type Digest Algorithms
+// https://www.iana.org/assignments/ds-rr-types/ds-rr-types.xhtml
+var dsDigestTypes = map[int]string{
+	1: "SHA-1",
+	2: "SHA-256",
+	3: "GOST R 34.11-94",
+	4: "SHA-384",
+	5: "GOST R 34.11-2012",
+	6: "SM3",
+}
+
+func algorithmName(algorithm int) string {
+	if name, found := dnssecAlgorithms[algorithm]; found {
+		return fmt.Sprintf("%v (%v)", algorithm, name)
+	}
+	return fmt.Sprintf("%v (unassigned)", algorithm)
+}
+
+func digestTypeName(digestType int) string {
+	if name, found := dsDigestTypes[digestType]; found {
+		return fmt.Sprintf("%v (%v)", digestType, name)
+	}
+	return fmt.Sprintf("%v (unassigned)", digestType)
+}
+
+// Print the secure DNS member of a domain
+func printSecureDNS(w io.Writer, secureDNS m.SecureDNS) {
+	fmt.Fprintf(w, "\n\nDNSSEC")
+	fmt.Fprintf(w, "\n\n\tZone Signed:\t\t%v", firstNonEmpty(boolString(secureDNS.ZoneSigned), "unknown"))
+	fmt.Fprintf(w, "\n\tDelegation Signed:\t%v", firstNonEmpty(boolString(secureDNS.DelegationSigned), "unknown"))
+	if secureDNS.MaxSigLife != 0 {
+		fmt.Fprintf(w, "\n\tMax Signature Life:\t%v seconds", secureDNS.MaxSigLife)
+	}
+
+	for _, ds := range secureDNS.DSData {
+		fmt.Fprintf(w, "\n\n\tDS Key Tag:\t\t%v", ds.KeyTag)
+		fmt.Fprintf(w, "\n\tAlgorithm:\t\t%v", algorithmName(ds.Algorithm))
+		fmt.Fprintf(w, "\n\tDigest Type:\t\t%v", digestTypeName(ds.DigestType))
+		fmt.Fprintf(w, "\n\tDigest:\t\t\t%v", strings.ToUpper(ds.Digest))
+	}
+	for _, key := range secureDNS.KeyData {
+		fmt.Fprintf(w, "\n\n\tDNSKEY Flags:\t\t%v%v", key.Flags, keyRole(key.Flags))
+		fmt.Fprintf(w, "\n\tProtocol:\t\t%v", key.Protocol)
+		fmt.Fprintf(w, "\n\tAlgorithm:\t\t%v", algorithmName(key.Algorithm))
+		fmt.Fprintf(w, "\n\tPublic Key:\t\t%v", key.PublicKey)
+	}
+}
+
+// Describe the zone key and secure entry point flags
+// https://datatracker.ietf.org/doc/html/rfc4034#section-2.1.1
+func keyRole(flags int) string {
+	switch {
+	case flags&0x0100 != 0 && flags&0x0001 != 0:
+		return " (KSK)"
+	case flags&0x0100 != 0:
+		return " (ZSK)"
+	}
+	return ""
+}
+
+// DS and DNSKEY records in zone file presentation format, one set per
+// domain. Records repeated by the registrar response are written once.
+// https://datatracker.ietf.org/doc/html/rfc4034#section-5.3
+type dsRenderer struct{}
+
+func (dsRenderer) Render(w io.Writer, results []Result) error {
+	var written []string
+	for _, result := range results {
+		if result.Domain == nil {
+			continue
+		}
+		owner := strings.ToLower(strings.TrimSuffix(firstNonEmpty(result.Domain.LdhName, result.Query), ".")) + "."
+
+		var records []string
+		for _, ds := range result.Domain.SecureDNS.DSData {
+			records = append(records, fmt.Sprintf("%v\tIN\tDS\t%v %v %v %v", owner, ds.KeyTag, ds.Algorithm, ds.DigestType, strings.ToUpper(ds.Digest)))
+		}
+		for _, key := range result.Domain.SecureDNS.KeyData {
+			records = append(records, fmt.Sprintf("%v\tIN\tDNSKEY\t%v %v %v %v", owner, key.Flags, key.Protocol, key.Algorithm, key.PublicKey))
+		}
+
+		var header bool
+		for _, record := range records {
+			if slices.Contains(written, record) {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(w, "; %v from %v\n", owner, result.ServerURL)
+				header = true
+			}
+			fmt.Fprintf(w, "%v\n", record)
+			written = append(written, record)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Check the DS records a registry publishes against the DNSKEYs expected
+// for the zone, without querying DNS
+// https://datatracker.ietf.org/doc/html/rfc4034#section-5.1.4
+
+// DNSKEY record read from a zone or key file
+type DNSKEY struct {
+	Owner     string `json:"owner"`
+	Flags     int    `json:"flags"`
+	Protocol  int    `json:"protocol"`
+	Algorithm int    `json:"algorithm"`
+	PublicKey string `json:"publicKey"`
+}
+
+// Result of checking one DS record or key. Status is "match", "orphaned"
+// (a DS no key produces), "missing" (a key no DS covers) or "unsupported"
+// (a digest type that cannot be computed).
+type DSCheck struct {
+	Status     string `json:"status"`
+	KeyTag     int    `json:"keyTag"`
+	Algorithm  int    `json:"algorithm"`
+	DigestType int    `json:"digestType,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	KeyFlags   int    `json:"keyFlags,omitempty"`
+}
+
+// Read the DNSKEY records of a zone file or a dnssec-keygen .key file.
+// Comments and records of other types are skipped, parenthesized records
+// may span lines. A key that is not valid base64 is an error rather than a
+// key no DS record can match.
+func ReadDNSKEYs(location string) ([]DNSKEY, error) {
+	data, err := os.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []DNSKEY
+	var owner, record string
+	depth := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		if comment := strings.Index(line, ";"); comment >= 0 {
+			line = line[:comment]
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "$") {
+			continue
+		}
+		// Lines starting with whitespace continue the previous owner
+		if depth == 0 && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			owner = strings.Fields(line)[0]
+		}
+		depth += strings.Count(line, "(") - strings.Count(line, ")")
+		record += " " + strings.NewReplacer("(", " ", ")", " ").Replace(line)
+		if depth > 0 {
+			continue
+		}
+
+		if key, ok := parseDNSKEY(owner, record); ok {
+			if _, err := key.rdata(); err != nil {
+				return nil, fmt.Errorf("%v: %v %v", location, owner, err)
+			}
+			keys = append(keys, key)
+		}
+		record = ""
+	}
+	return keys, nil
+}
+
+// Parse the fields after the DNSKEY type: flags, protocol, algorithm and the
+// base64 public key, which may contain spaces
+func parseDNSKEY(owner string, record string) (DNSKEY, bool) {
+	fields := strings.Fields(record)
+	for i, field := range fields {
+		if !strings.EqualFold(field, "DNSKEY") || len(fields) < i+5 {
+			continue
+		}
+		flags, flagsErr := strconv.Atoi(fields[i+1])
+		protocol, protocolErr := strconv.Atoi(fields[i+2])
+		algorithm, algorithmErr := strconv.Atoi(fields[i+3])
+		if flagsErr != nil || protocolErr != nil || algorithmErr != nil {
+			return DNSKEY{}, false
+		}
+		return DNSKEY{Owner: owner, Flags: flags, Protocol: protocol, Algorithm: algorithm, PublicKey: strings.Join(fields[i+4:], "")}, true
+	}
+	return DNSKEY{}, false
+}
+
+// DNSKEY RDATA in wire format
+func (key DNSKEY) rdata() ([]byte, error) {
+	publicKey, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("DNSKEY public key is not base64: %v", err)
+	}
+	rdata := binary.BigEndian.AppendUint16(nil, uint16(key.Flags))
+	rdata = append(rdata, byte(key.Protocol), byte(key.Algorithm))
+	return append(rdata, publicKey...), nil
+}
+
+// Key tag of a DNSKEY
+// https://datatracker.ietf.org/doc/html/rfc4034#appendix-B
+func (key DNSKEY) keyTag() (int, error) {
+	rdata, err := key.rdata()
+	if err != nil {
+		return 0, err
+	}
+	var accumulator uint32
+	for i, octet := range rdata {
+		if i&1 == 1 {
+			accumulator += uint32(octet)
+		} else {
+			accumulator += uint32(octet) << 8
+		}
+	}
+	accumulator += accumulator >> 16 & 0xFFFF
+	return int(accumulator & 0xFFFF), nil
+}
+
+// Owner name in canonical wire format
+// https://datatracker.ietf.org/doc/html/rfc4034#section-6.2
+func canonicalName(name string) []byte {
+	var wire []byte
+	for _, label := range strings.Split(strings.TrimSuffix(strings.ToLower(name), "."), ".") {
+		if label == "" {
+			continue
+		}
+		wire = append(wire, byte(len(label)))
+		wire = append(wire, label...)
+	}
+	return append(wire, 0)
+}
+
+// Compute the DS digest of a key for a zone
+func computeDS(zone string, key DNSKEY, digestType int) (string, error) {
+	var digest hash.Hash
+	switch digestType {
+	case 1:
+		digest = sha1.New()
+	case 2:
+		digest = sha256.New()
+	case 4:
+		digest = sha512.New384()
+	default:
+		return "", fmt.Errorf("unsupported digest type %v", digestType)
+	}
+
+	rdata, err := key.rdata()
+	if err != nil {
+		return "", err
+	}
+	digest.Write(canonicalName(zone))
+	digest.Write(rdata)
+	return strings.ToUpper(hex.EncodeToString(digest.Sum(nil))), nil
+}
+
+// Check each DS record against the keys of the zone, then report the secure
+// entry point keys (all zone keys when none has the SEP flag) that no DS
+// record covers
+func VerifyDS(zone string, dsData []m.DSData, keys []DNSKEY) []DSCheck {
+	var checks []DSCheck
+	var zoneKeys []DNSKEY
+	for _, key := range keys {
+		if key.Owner == "@" || strings.EqualFold(strings.TrimSuffix(key.Owner, "."), strings.TrimSuffix(zone, ".")) {
+			zoneKeys = append(zoneKeys, key)
+		}
+	}
+	covered := make([]bool, len(zoneKeys))
+
+	for _, ds := range dsData {
+		check := DSCheck{Status: "orphaned", KeyTag: ds.KeyTag, Algorithm: ds.Algorithm, DigestType: ds.DigestType, Digest: strings.ToUpper(ds.Digest)}
+		for i, key := range zoneKeys {
+			if tag, err := key.keyTag(); err != nil || tag != ds.KeyTag || key.Algorithm != ds.Algorithm {
+				continue
+			}
+			digest, err := computeDS(zone, key, ds.DigestType)
+			if err != nil {
+				check.Status = "unsupported"
+				covered[i] = true
+				continue
+			}
+			if digest == check.Digest {
+				check.Status = "match"
+				check.KeyFlags = key.Flags
+				covered[i] = true
+				break
+			}
+		}
+		checks = append(checks, check)
+	}
+
+	sepOnly := false
+	for _, key := range zoneKeys {
+		if key.Flags&0x0001 != 0 {
+			sepOnly = true
+		}
+	}
+	for i, key := range zoneKeys {
+		if covered[i] || key.Flags&0x0100 == 0 || (sepOnly && key.Flags&0x0001 == 0) {
+			continue
+		}
+		tag, _ := key.keyTag()
+		digest, _ := computeDS(zone, key, 2)
+		checks = append(checks, DSCheck{Status: "missing", KeyTag: tag, Algorithm: key.Algorithm, DigestType: 2, Digest: digest, KeyFlags: key.Flags})
+	}
+	return checks
+}
+
+// Print DS checks as a table. Missing keys show the SHA-256 DS they need.
+func PrintDSChecks(w io.Writer, zone string, server string, checks []DSCheck) {
+	fmt.Fprintf(w, "\nDS Verification: %v", zone)
+	fmt.Fprintf(w, "\n---------------------------------------------------------------")
+	fmt.Fprintf(w, "\nRDAP Data Source: %v\n\n", server)
+	if len(checks) == 0 {
+		fmt.Fprintf(w, "(=) No DS records published and no keys expecting one\n")
+		return
+	}
+
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(table, "Status\tKey Tag\tAlgorithm\tDigest Type\tDigest\n")
+	for _, check := range checks {
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\n", strings.ToUpper(check.Status), check.KeyTag, algorithmName(check.Algorithm), digestTypeName(check.DigestType), check.Digest)
+	}
+	table.Flush()
+}
+
+// Write DS checks as a JSON array
+func WriteDSChecksJSON(w io.Writer, checks []DSCheck) error {
+	if checks == nil {
+		checks = []DSCheck{}
+	}
+	output, err := json.MarshalIndent(checks, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(output, '\n'))
+	return err
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// The key of the examples in RFC 4034 section 5.4 and RFC 4509 section 2.3
+const rfcPublicKey = "AQOeiiR0GOMYkDshWoSKz9XzfwJr1AYtsmx3TGkJaNXVbfi/2pHm822aJ5iI9BMzNXxeYCmZDRD99WYwYqUSdjMmmAphXdvxegXd/M5+X7OrzKBaMbCVdFLUUh6DhweJBjEVv5f2wwjM9XzcnOf+EPbtG9DMBmADjFDc2w/rljwvFw=="
+
+var rfcKey = DNSKEY{Owner: "dskey.example.com.", Flags: 256, Protocol: 3, Algorithm: 5, PublicKey: rfcPublicKey}
+
+const (
+	rfcSHA1DS   = "2BB183AF5F22588179A53B0A98631FAD1A292118"
+	rfcSHA256DS = "D4B7D520E7BB5F0F67674A0CCEB1E3E0614B93C4F9E99B8383F6A1E4469DA50A"
+)
+
+func TestDSKnownAnswers(t *testing.T) {
+	tag, err := rfcKey.keyTag()
+	if err != nil || tag != 60485 {
+		t.Errorf("key tag %v, error %v, want 60485", tag, err)
+	}
+
+	tests := []struct {
+		digestType int
+		want       string
+	}{
+		{digestType: 1, want: rfcSHA1DS},
+		{digestType: 2, want: rfcSHA256DS},
+	}
+	for _, test := range tests {
+		// Owner names compare case-insensitively, with or without the root
+		for _, zone := range []string{"dskey.example.com.", "DSKEY.Example.com"} {
+			if digest, err := computeDS(zone, rfcKey, test.digestType); err != nil || digest != test.want {
+				t.Errorf("digest type %v for %v: got %v, error %v, want %v", test.digestType, zone, digest, err, test.want)
+			}
+		}
+	}
+
+	if _, err := computeDS("dskey.example.com", rfcKey, 3); err == nil {
+		t.Error("expected an error for GOST digests")
+	}
+}
+
+func TestReadDNSKEYs(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []DNSKEY
+		wantErr bool
+	}{
+		{
+			name: "multi-line record from RFC 4034",
+			file: `$ORIGIN example.com.
+dskey.example.com. 86400 IN DNSKEY 256 3 5 ( AQOeiiR0GOMYkDshWoSKz9Xz
+                                             fwJr1AYtsmx3TGkJaNXVbfi/
+                                             2pHm822aJ5iI9BMzNXxeYCmZ
+                                             DRD99WYwYqUSdjMmmAphXdvx
+                                             egXd/M5+X7OrzKBaMbCVdFLU
+                                             Uh6DhweJBjEVv5f2wwjM9Xzc
+                                             nOf+EPbtG9DMBmADjFDc2w/r
+                                             ljwvFw==
+                                             ) ;  key id = 60485
+`,
+			want: []DNSKEY{rfcKey},
+		},
+		{
+			name: "comments, other records and owners continued by indentation",
+			file: `; This is a key-signing key, keyid 60485, for dskey.example.com.
+; Created: 20260101000000
+dskey.example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. ( 1 7200 3600 1209600 3600 )
+dskey.example.com. 86400 IN DNSKEY 257 3 5 AQOeiiR0GOMYkDshWoSKz9XzfwJr1AYtsmx3TGkJaNXVbfi/2pHm822aJ5iI9BMzNXxeYCmZ DRD99WYwYqUSdjMmmAphXdvxegXd/M5+X7OrzKBaMbCVdFLUUh6DhweJBjEVv5f2wwjM9XzcnOf+EPbtG9DMBmADjFDc2w/rljwvFw== ; KSK
+	86400 IN DNSKEY 256 3 5 ` + rfcPublicKey + `
+www 300 IN A 192.0.2.1
+`,
+			want: []DNSKEY{
+				{Owner: "dskey.example.com.", Flags: 257, Protocol: 3, Algorithm: 5, PublicKey: rfcPublicKey},
+				rfcKey,
+			},
+		},
+		{
+			name: "CRLF line endings",
+			file: "dskey.example.com. IN DNSKEY 256 3 5 (\r\n " + rfcPublicKey[:40] + "\r\n " + rfcPublicKey[40:] + " )\r\n\r\n",
+			want: []DNSKEY{rfcKey},
+		},
+		{
+			name: "dnssec-keygen .key file with a relative owner",
+			file: "; This is a zone-signing key, keyid 60485, for example.com.\n@ IN DNSKEY 256 3 5 " + rfcPublicKey + "\n",
+			want: []DNSKEY{{Owner: "@", Flags: 256, Protocol: 3, Algorithm: 5, PublicKey: rfcPublicKey}},
+		},
+		{
+			name:    "key that is not base64",
+			file:    "dskey.example.com. IN DNSKEY 256 3 5 AQOeiiR0GOMY!kDsh\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			location := filepath.Join(t.TempDir(), "keys.zone")
+			if err := os.WriteFile(location, []byte(test.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			keys, err := ReadDNSKEYs(location)
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "not base64") {
+					t.Errorf("got %+v, error %v, want a base64 error", keys, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keys, test.want) {
+				t.Errorf("got %+v, want %+v", keys, test.want)
+			}
+		})
+	}
+}
+
+func TestVerifyDS(t *testing.T) {
+	ksk := rfcKey
+	ksk.Flags = 257
+	sha1DS := m.DSData{KeyTag: 60485, Algorithm: 5, DigestType: 1, Digest: strings.ToLower(rfcSHA1DS)}
+	sha256DS := m.DSData{KeyTag: 60485, Algorithm: 5, DigestType: 2, Digest: rfcSHA256DS}
+	mismatch := m.DSData{KeyTag: 60485, Algorithm: 5, DigestType: 2, Digest: strings.Repeat("AB", 32)}
+
+	tests := []struct {
+		name   string
+		dsData []m.DSData
+		keys   []DNSKEY
+		want   []DSCheck
+	}{
+		{
+			name:   "both digests match",
+			dsData: []m.DSData{sha1DS, sha256DS},
+			keys:   []DNSKEY{rfcKey},
+			want: []DSCheck{
+				{Status: "match", KeyTag: 60485, Algorithm: 5, DigestType: 1, Digest: rfcSHA1DS, KeyFlags: 256},
+				{Status: "match", KeyTag: 60485, Algorithm: 5, DigestType: 2, Digest: rfcSHA256DS, KeyFlags: 256},
+			},
+		},
+		{
+			name:   "digest mismatch leaves the DS orphaned and the key missing",
+			dsData: []m.DSData{mismatch},
+			keys:   []DNSKEY{rfcKey},
+			want: []DSCheck{
+				{Status: "orphaned", KeyTag: 60485, Algorithm: 5, DigestType: 2, Digest: mismatch.Digest},
+				{Status: "missing", KeyTag: 60485, Algorithm: 5, DigestType: 2, Digest: rfcSHA256DS, KeyFlags: 256},
+			},
+		},
+		{
+			name:   "algorithm mismatch",
+			dsData: []m.DSData{{KeyTag: 60485, Algorithm: 8, DigestType: 2, Digest: rfcSHA256DS}},
+			keys:   []DNSKEY{ksk},
+			want: []DSCheck{
+				{Status: "orphaned", KeyTag: 60485, Algorithm: 8, DigestType: 2, Digest: rfcSHA256DS},
+				{Status: "missing", KeyTag: 60486, Algorithm: 5, DigestType: 2, Digest: mustComputeDS(t, ksk), KeyFlags: 257},
+			},
+		},
+		{
+			name:   "unsupported digest type",
+			dsData: []m.DSData{{KeyTag: 60485, Algorithm: 5, DigestType: 3, Digest: "00"}},
+			keys:   []DNSKEY{rfcKey},
+			want:   []DSCheck{{Status: "unsupported", KeyTag: 60485, Algorithm: 5, DigestType: 3, Digest: "00"}},
+		},
+		{
+			name: "only SEP keys need a DS once any key has the flag",
+			keys: []DNSKEY{rfcKey, ksk},
+			want: []DSCheck{{Status: "missing", KeyTag: 60486, Algorithm: 5, DigestType: 2, Digest: mustComputeDS(t, ksk), KeyFlags: 257}},
+		},
+		{
+			name:   "keys of other owners are ignored",
+			dsData: []m.DSData{sha256DS},
+			keys:   []DNSKEY{{Owner: "other.example.com.", Flags: 256, Protocol: 3, Algorithm: 5, PublicKey: rfcPublicKey}},
+			want:   []DSCheck{{Status: "orphaned", KeyTag: 60485, Algorithm: 5, DigestType: 2, Digest: rfcSHA256DS}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if checks := VerifyDS("dskey.example.com", test.dsData, test.keys); !reflect.DeepEqual(checks, test.want) {
+				t.Errorf("got %+v\nwant %+v", checks, test.want)
+			}
+		})
+	}
+}
+
+func mustComputeDS(t *testing.T, key DNSKEY) string {
+	t.Helper()
+	digest, err := computeDS(key.Owner, key, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
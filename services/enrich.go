@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Who hosts the DNS of a domain: the network and origin ASN of every
+// nameserver glue address
+
+// Hosting of one nameserver address
+type NameserverHost struct {
+	Domain     string   `json:"domain"`
+	Nameserver string   `json:"nameserver"`
+	Address    string   `json:"address"`
+	Network    string   `json:"network,omitempty"`
+	CIDR       []string `json:"cidr,omitempty"`
+	Org        string   `json:"org,omitempty"`
+	Country    string   `json:"country,omitempty"`
+	ASNs       []string `json:"asns,omitempty"`
+}
+
+// Look up the network of every nameserver glue address of the domain
+// results, and the autnum of each origin ASN the networks publish. Every
+// address and ASN is looked up once; the bootstrap registries are cached.
+func EnrichNameservers(results []Result, registryBaseURL string) []Result {
+	var enrichment []Result
+	looked := map[string]bool{}
+
+	lookup := func(key string, lookupFunc func() (Result, error)) {
+		if looked[key] {
+			return
+		}
+		looked[key] = true
+		result, err := lookupFunc()
+		if err != nil {
+			fmt.Fprintf(Status, "\n(!) Lookup failed for %v: %v", key, err)
+			return
+		}
+		enrichment = append(enrichment, result)
+	}
+
+	for _, address := range nameserverAddresses(results) {
+		fmt.Fprintf(Status, "\n(+) Querying RDAP Service for nameserver address:\t%v", address)
+		lookup(address.String(), func() (Result, error) { return LookupIP(address, registryBaseURL) })
+	}
+
+	for _, result := range slices.Clone(enrichment) {
+		for _, asn := range originAutnums(*result.IPNetwork) {
+			ASN := "AS" + strconv.FormatUint(uint64(asn), 10)
+			lookup(ASN, func() (Result, error) { return LookupAutonum(ASN, registryBaseURL+"asn.json") })
+		}
+	}
+
+	return enrichment
+}
+
+// Unique glue addresses of the domain results
+func nameserverAddresses(results []Result) []netip.Addr {
+	var addresses []netip.Addr
+	for _, result := range results {
+		if result.Domain == nil {
+			continue
+		}
+		for _, nameserver := range result.Domain.Nameservers {
+			for _, value := range append(slices.Clone(nameserver.IPAddresses.V4), nameserver.IPAddresses.V6...) {
+				address, err := netip.ParseAddr(value)
+				address = address.Unmap()
+				if err == nil && !slices.Contains(addresses, address) {
+					addresses = append(addresses, address)
+				}
+			}
+		}
+	}
+	return addresses
+}
+
+// Match the nameserver addresses of each domain with the network and autnum
+// results looked up for them
+func NameserverHosts(results []Result) []NameserverHost {
+	networks := map[string]Result{}
+	autnums := map[uint32]Result{}
+	for _, result := range results {
+		if result.IPNetwork != nil {
+			networks[normalizeAddress(result.Query)] = result
+		} else if result.Autonum != nil {
+			autnums[queriedASN(result)] = result
+		}
+	}
+
+	var hosts []NameserverHost
+	for _, result := range results {
+		if result.Domain == nil {
+			continue
+		}
+		domain := strings.ToLower(firstNonEmpty(result.Domain.LdhName, result.Query))
+		for _, nameserver := range result.Domain.Nameservers {
+			for _, address := range append(slices.Clone(nameserver.IPAddresses.V4), nameserver.IPAddresses.V6...) {
+				host := NameserverHost{Domain: domain, Nameserver: strings.ToLower(nameserver.LdhName), Address: address}
+				if network, found := networks[normalizeAddress(address)]; found {
+					summary := Summarize(network)
+					host.Network = firstNonEmpty(network.IPNetwork.Name, network.IPNetwork.Handle)
+					host.CIDR = summary.NetworkCIDR
+					host.Org = summary.RegistrantOrg
+					host.Country = network.IPNetwork.Country
+					for _, asn := range originAutnums(*network.IPNetwork) {
+						ASN := "AS" + strconv.FormatUint(uint64(asn), 10)
+						if autnum, found := autnums[asn]; found && autnum.Autonum.Name != "" {
+							ASN += " " + autnum.Autonum.Name
+						}
+						host.ASNs = append(host.ASNs, ASN)
+					}
+				}
+				if !slices.ContainsFunc(hosts, func(existing NameserverHost) bool {
+					return existing.Domain == host.Domain && existing.Nameserver == host.Nameserver && existing.Address == host.Address
+				}) {
+					hosts = append(hosts, host)
+				}
+			}
+		}
+	}
+	return hosts
+}
+
+// Canonical form of an address, IPv4-mapped IPv6 addresses as IPv4 the way
+// LookupIP queries them
+func normalizeAddress(value string) string {
+	if address, err := netip.ParseAddr(value); err == nil {
+		return address.Unmap().String()
+	}
+	return value
+}
+
+// Print the nameserver hosting report
+func printNameserverHosts(w io.Writer, hosts []NameserverHost) {
+	fmt.Fprintf(w, "\n\nNameserver Hosting")
+	fmt.Fprintf(w, "\n---------------------------------------------------------------\n")
+	if len(hosts) == 0 {
+		fmt.Fprintf(w, "(=) No nameserver glue addresses published\n")
+		return
+	}
+
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(table, "Domain\tNameserver\tAddress\tNetwork\tCIDR\tOrg\tCountry\tASN\n")
+	for _, host := range hosts {
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", host.Domain, host.Nameserver, host.Address, host.Network,
+			strings.Join(host.CIDR, ", "), host.Org, host.Country, strings.Join(host.ASNs, ", "))
+	}
+	table.Flush()
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNameserverHosts(t *testing.T) {
+	domain := decodedResult(t, "Example.com", "domain", `{"objectClassName": "domain", "ldhName": "EXAMPLE.COM", "nameservers": [
+		{"objectClassName": "nameserver", "ldhName": "NS1.Host.Example", "ipAddresses": {"v4": ["192.0.2.53"], "v6": ["2001:db8::53"]}},
+		{"objectClassName": "nameserver", "ldhName": "ns2.host.example", "ipAddresses": {"v6": ["::ffff:192.0.2.54"]}},
+		{"objectClassName": "nameserver", "ldhName": "ns3.host.example"}
+	]}`)
+	network := decodedResult(t, "192.0.2.53", "ip network", `{"objectClassName": "ip network", "handle": "NET-192-0-2-0-1", "name": "HOSTING-NET",
+		"startAddress": "192.0.2.0", "endAddress": "192.0.2.255", "country": "US", "arin_originas0_originautnums": [64500, 64501],
+		"entities": [{"objectClassName": "entity", "roles": ["registrant"], "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example Hosting"]]]}]}`)
+	mapped := network
+	mapped.Query = "::ffff:192.0.2.54"
+	// The registry answers for AS64500 with the block holding it
+	block := decodedResult(t, "AS64500", "autnum", `{"objectClassName": "autnum", "handle": "AS64496-AS64511", "startAutnum": 64496, "endAutnum": 64511, "name": "EXAMPLE-HOSTING"}`)
+
+	hosts := NameserverHosts([]Result{domain, network, mapped, block})
+
+	hosting := NameserverHost{
+		Domain: "example.com", Network: "HOSTING-NET", CIDR: []string{"192.0.2.0/24"}, Org: "Example Hosting", Country: "US",
+		ASNs: []string{"AS64500 EXAMPLE-HOSTING", "AS64501"},
+	}
+	host := func(nameserver string, address string, network NameserverHost) NameserverHost {
+		network.Nameserver, network.Address = nameserver, address
+		return network
+	}
+	want := []NameserverHost{
+		host("ns1.host.example", "192.0.2.53", hosting),
+		host("ns1.host.example", "2001:db8::53", NameserverHost{Domain: "example.com"}),
+		host("ns2.host.example", "::ffff:192.0.2.54", hosting),
+	}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("got %+v\nwant %+v", hosts, want)
+	}
+}
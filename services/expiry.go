@@ -0,0 +1,134 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Days until each domain's "expiration" event
+
+// Expiration of one domain
+type Expiry struct {
+	Domain    string     `json:"domain"`
+	Expires   *time.Time `json:"expires,omitempty"`
+	DaysLeft  *int       `json:"daysLeft,omitempty"`
+	State     string     `json:"state"`
+	Registrar string     `json:"registrar,omitempty"`
+	Server    string     `json:"server,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Look up every domain and report its expiration, soonest first. State is
+// "expired", "critical", "warning" or "ok", or "unknown" when the lookup
+// failed or no expiration event was published.
+func CheckExpiry(domains []string, registryURL string, warning int, critical int) []Expiry {
+	var expiries []Expiry
+	now := time.Now()
+
+	for _, domain := range domains {
+		fmt.Fprintf(Status, "\n(+) Querying RDAP Service for domain:\t%v", domain)
+		results, err := LookupDomain(domain, registryURL)
+		if err != nil {
+			expiries = append(expiries, Expiry{Domain: domain, State: "unknown", Error: err.Error()})
+			continue
+		}
+		expiries = append(expiries, domainExpiry(domain, results, now, warning, critical))
+	}
+
+	slices.SortStableFunc(expiries, func(a Expiry, b Expiry) int {
+		switch {
+		case a.DaysLeft == nil && b.DaysLeft == nil:
+			return 0
+		case a.DaysLeft == nil:
+			return 1
+		case b.DaysLeft == nil:
+			return -1
+		}
+		return *a.DaysLeft - *b.DaysLeft
+	})
+	return expiries
+}
+
+// Expiration of a domain from its lookup results, preferring the registry's
+// date and falling back to a registrar response
+func domainExpiry(domain string, results []Result, now time.Time, warning int, critical int) Expiry {
+	expiry := Expiry{Domain: domain, State: "unknown"}
+
+	for _, result := range results {
+		if result.Domain == nil {
+			continue
+		}
+		if expiry.Registrar == "" {
+			expiry.Registrar = Summarize(result).Registrar
+		}
+		expires, found := eventTime(result.Domain.Events, "expiration")
+		if !found {
+			continue
+		}
+		daysLeft := int(math.Floor(expires.Sub(now).Hours() / 24))
+		expiry.Expires, expiry.DaysLeft, expiry.Server = &expires, &daysLeft, result.ServerURL
+		expiry.State = expiryState(daysLeft, warning, critical)
+		break
+	}
+	if expiry.Expires == nil {
+		expiry.Error = "no expiration event"
+	}
+	return expiry
+}
+
+func expiryState(daysLeft int, warning int, critical int) string {
+	switch {
+	case daysLeft < 0:
+		return "expired"
+	case daysLeft <= critical:
+		return "critical"
+	case daysLeft <= warning:
+		return "warning"
+	}
+	return "ok"
+}
+
+// Exit code of the expiry command: 2 when any domain is expired or inside the
+// critical window, 1 when a domain could not be checked, otherwise 0
+func ExpiryExitCode(expiries []Expiry) int {
+	exitCode := 0
+	for _, expiry := range expiries {
+		if expiry.State == "expired" || expiry.State == "critical" {
+			return 2
+		} else if expiry.State == "unknown" {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// Print expirations as a table
+func PrintExpiry(w io.Writer, expiries []Expiry) {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(table, "Domain\tExpires\tDays Left\tState\tRegistrar\n")
+	for _, expiry := range expiries {
+		expires, daysLeft := expiry.Error, ""
+		if expiry.Expires != nil {
+			expires = expiry.Expires.Format("2006-01-02")
+			daysLeft = fmt.Sprint(*expiry.DaysLeft)
+		}
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\n", expiry.Domain, expires, daysLeft, strings.ToUpper(expiry.State), expiry.Registrar)
+	}
+	table.Flush()
+}
+
+// Write expirations as a JSON array
+func WriteExpiryJSON(w io.Writer, expiries []Expiry) error {
+	output, err := json.MarshalIndent(expiries, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(output, '\n'))
+	return err
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// A domain response expiring at the given time
+func expiringResult(t *testing.T, serverURL string, expires string) Result {
+	t.Helper()
+	events := ""
+	if expires != "" {
+		events = fmt.Sprintf(`, "events": [{"eventAction": "expiration", "eventDate": %q}]`, expires)
+	}
+	result := decodedResult(t, "example.com", "domain", `{"objectClassName": "domain", "ldhName": "example.com"`+events+`}`)
+	result.ServerURL = serverURL
+	return result
+}
+
+func TestDomainExpiry(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	days := func(days int) string {
+		return now.AddDate(0, 0, days).Format(time.RFC3339)
+	}
+
+	// Windows of the default flags: warning 30 days, critical 7
+	tests := []struct {
+		name     string
+		expires  string
+		daysLeft int
+		state    string
+		exitCode int
+	}{
+		{name: "expired yesterday", expires: days(-1), daysLeft: -1, state: "expired", exitCode: 2},
+		{name: "expired an hour ago", expires: now.Add(-time.Hour).Format(time.RFC3339), daysLeft: -1, state: "expired", exitCode: 2},
+		{name: "expires later today", expires: now.Add(time.Hour).Format(time.RFC3339), daysLeft: 0, state: "critical", exitCode: 2},
+		{name: "last day of the critical window", expires: days(7), daysLeft: 7, state: "critical", exitCode: 2},
+		{name: "first day after the critical window", expires: days(8), daysLeft: 8, state: "warning", exitCode: 0},
+		{name: "last day of the warning window", expires: days(30), daysLeft: 30, state: "warning", exitCode: 0},
+		{name: "first day after the warning window", expires: days(31), daysLeft: 31, state: "ok", exitCode: 0},
+		{name: "no expiration event", state: "unknown", exitCode: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expiry := domainExpiry("example.com", []Result{expiringResult(t, "https://rdap.example/domain/example.com", test.expires)}, now, 30, 7)
+			if expiry.State != test.state {
+				t.Errorf("state %v, want %v", expiry.State, test.state)
+			}
+			if test.expires == "" {
+				if expiry.DaysLeft != nil || expiry.Error != "no expiration event" {
+					t.Errorf("unexpected expiry: %+v", expiry)
+				}
+			} else if expiry.DaysLeft == nil || *expiry.DaysLeft != test.daysLeft {
+				t.Errorf("days left %v, want %v", expiry.DaysLeft, test.daysLeft)
+			}
+			if exitCode := ExpiryExitCode([]Expiry{expiry}); exitCode != test.exitCode {
+				t.Errorf("exit code %v, want %v", exitCode, test.exitCode)
+			}
+		})
+	}
+}
+
+// The registry's date wins over a registrar's, which fills in when the
+// registry publishes none
+func TestDomainExpiryServer(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	registry := "https://registry.example/domain/example.com"
+	registrar := "https://registrar.example/domain/example.com"
+
+	expiry := domainExpiry("example.com", []Result{
+		expiringResult(t, registry, "2026-03-05T12:00:00Z"),
+		expiringResult(t, registrar, "2027-03-05T12:00:00Z"),
+	}, now, 30, 7)
+	if expiry.Server != registry || *expiry.DaysLeft != 4 {
+		t.Errorf("unexpected expiry: %+v", expiry)
+	}
+
+	expiry = domainExpiry("example.com", []Result{
+		expiringResult(t, registry, ""),
+		expiringResult(t, registrar, "2026-04-05T12:00:00Z"),
+	}, now, 30, 7)
+	if expiry.Server != registrar || expiry.State != "ok" || expiry.Error != "" {
+		t.Errorf("unexpected expiry: %+v", expiry)
+	}
+}
+
+// A critical domain anywhere in the batch outranks one that failed
+func TestExpiryExitCode(t *testing.T) {
+	tests := []struct {
+		states   []string
+		exitCode int
+	}{
+		{states: nil, exitCode: 0},
+		{states: []string{"ok", "warning"}, exitCode: 0},
+		{states: []string{"ok", "unknown"}, exitCode: 1},
+		{states: []string{"unknown", "critical"}, exitCode: 2},
+		{states: []string{"expired", "unknown"}, exitCode: 2},
+	}
+
+	for _, test := range tests {
+		var expiries []Expiry
+		for _, state := range test.states {
+			expiries = append(expiries, Expiry{Domain: "example.com", State: state})
+		}
+		if exitCode := ExpiryExitCode(expiries); exitCode != test.exitCode {
+			t.Errorf("%v: exit code %v, want %v", test.states, exitCode, test.exitCode)
+		}
+	}
+}
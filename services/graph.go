@@ -0,0 +1,281 @@
+package services
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Graph export of lookups for pivoting on shared infrastructure
+//
+// dot:       Graphviz DOT    https://graphviz.org/doc/info/lang.html
+// graphml:   GraphML         http://graphml.graphdrawing.org/
+// graphjson: JSON node-link, as read by NetworkX and D3
+//
+// Nodes are domains, nameservers, IP addresses, networks, autnums and
+// entities. Nodes are keyed by name or handle, so a nameserver or registrar
+// shared by several domains in a batch is a single node.
+
+type exportNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+}
+
+type exportEdge struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Relation string `json:"relation"`
+}
+
+type exportGraph struct {
+	Nodes []*exportNode
+	Edges []exportEdge
+	index map[string]*exportNode
+	edges map[exportEdge]bool
+}
+
+// Build the graph of every result. Links followed during traversal are added
+// as edges between the objects they joined, labelled with the link relation.
+func buildExportGraph(results []Result, traversal *Graph) *exportGraph {
+	graph := &exportGraph{index: map[string]*exportNode{}, edges: map[exportEdge]bool{}}
+
+	// Node of the object each result was fetched from, by traversal node ID
+	objectIDs := map[string]string{}
+	for _, result := range results {
+		objectIDs[normalizeURL(result.ServerURL)] = graph.addResult(result)
+	}
+
+	if traversal != nil {
+		for _, edge := range traversal.Edges {
+			graph.edge(objectIDs[edge.From], objectIDs[edge.To], edge.Rel)
+		}
+	}
+	return graph
+}
+
+// Add the objects of a result, returning the ID of its main object
+func (graph *exportGraph) addResult(result Result) string {
+	switch {
+	case result.Domain != nil:
+		domain := result.Domain
+		domainID := graph.node("domain", firstNonEmpty(strings.ToLower(strings.TrimSuffix(domain.LdhName, ".")), strings.ToLower(result.Query)))
+		for _, nameserver := range domain.Nameservers {
+			if nameserver.LdhName == "" {
+				continue
+			}
+			nameserverID := graph.node("nameserver", strings.ToLower(strings.TrimSuffix(nameserver.LdhName, ".")))
+			graph.edge(domainID, nameserverID, "nameserver")
+			for _, address := range append(nameserver.IPAddresses.V4, nameserver.IPAddresses.V6...) {
+				graph.edge(nameserverID, graph.node("ip", address), "resolves-to")
+			}
+		}
+		if domain.Network != nil {
+			graph.addNetwork(domainID, "hosted-in", *domain.Network)
+		}
+		graph.addEntities(domainID, domain.Entities)
+		return domainID
+	case result.IPNetwork != nil:
+		source := ""
+		if _, err := netip.ParseAddr(result.Query); err == nil {
+			source = graph.node("ip", result.Query)
+		}
+		return graph.addNetwork(source, "belongs-to", *result.IPNetwork)
+	case result.Autonum != nil:
+		autnumID := graph.node("autnum", "AS"+strconv.FormatUint(uint64(queriedASN(result)), 10))
+		graph.addEntities(autnumID, result.Autonum.Entities)
+		return autnumID
+	case result.Entity != nil:
+		entityID := graph.entityNode("", *result.Entity)
+		graph.addEntities(entityID, result.Entity.Entities)
+		return entityID
+	case result.Nameserver != nil:
+		nameserverID := graph.node("nameserver", strings.ToLower(strings.TrimSuffix(result.Nameserver.LdhName, ".")))
+		graph.addEntities(nameserverID, result.Nameserver.Entities)
+		return nameserverID
+	}
+	return ""
+}
+
+// Return the ID of a node, adding it when new
+func (graph *exportGraph) node(nodeType string, label string) string {
+	ID := nodeType + ":" + strings.ToLower(label)
+	if _, found := graph.index[ID]; !found {
+		node := &exportNode{ID: ID, Type: nodeType, Label: label}
+		graph.index[ID] = node
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	return ID
+}
+
+// Add an edge once
+func (graph *exportGraph) edge(source string, target string, relation string) {
+	edge := exportEdge{Source: source, Target: target, Relation: relation}
+	if source == "" || target == "" || source == target || graph.edges[edge] {
+		return
+	}
+	graph.edges[edge] = true
+	graph.Edges = append(graph.Edges, edge)
+}
+
+// Add a network, its parent, origin autnums and entities, returning the
+// network's ID
+func (graph *exportGraph) addNetwork(source string, relation string, network m.IPNetwork) string {
+	var summary Summary
+	summarizeNetwork(&summary, network)
+	networkID := graph.node("network", firstNonEmpty(network.Handle, strings.Join(summary.NetworkCIDR, ", "), network.StartAddress+" - "+network.EndAddress))
+	graph.edge(source, networkID, relation)
+
+	if network.ParentHandle != "" {
+		graph.edge(networkID, graph.node("network", network.ParentHandle), "parent")
+	}
+	for _, asn := range originAutnums(network) {
+		graph.edge(networkID, graph.node("autnum", "AS"+strconv.FormatUint(uint64(asn), 10)), "originated-by")
+	}
+	graph.addEntities(networkID, network.Entities)
+	return networkID
+}
+
+// Add entities with an edge per role
+func (graph *exportGraph) addEntities(source string, entities []m.Entity) {
+	for _, entity := range entities {
+		entityID := graph.entityNode(source, entity)
+		if entityID == "" {
+			graph.addEntities(source, entity.Entities)
+			continue
+		}
+
+		for _, role := range entity.Roles {
+			graph.edge(source, entityID, role)
+		}
+		if len(entity.Roles) == 0 {
+			graph.edge(source, entityID, "entity")
+		}
+		graph.addEntities(entityID, entity.Entities)
+	}
+}
+
+// Return the ID of an entity's node, adding it when new, or "" for an entity
+// with neither a name nor a handle. Entities are keyed by handle, or by name
+// and email when they have none; redacted contacts without a handle are kept
+// apart so they do not join unrelated objects.
+func (graph *exportGraph) entityNode(source string, entity m.Entity) string {
+	name := entityName(entity)
+	label := firstNonEmpty(name, entity.Handle)
+	if label == "" {
+		return ""
+	}
+
+	key := entity.Handle
+	if key == "" {
+		key = name
+		if email := vcardText(entity, "email"); email != "" {
+			key += " <" + email + ">"
+		}
+		if strings.Contains(strings.ToLower(key), "redacted") {
+			key = source + " " + key
+		}
+	}
+	entityID := "entity:" + strings.ToLower(key)
+	if _, found := graph.index[entityID]; !found {
+		node := &exportNode{ID: entityID, Type: "entity", Label: label}
+		graph.index[entityID] = node
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	return entityID
+}
+
+// Graphviz DOT. The node type is set as the class attribute, used for styling
+// SVG output.
+type dotRenderer struct {
+	traversal *Graph
+}
+
+func (r dotRenderer) Render(w io.Writer, results []Result) error {
+	graph := buildExportGraph(results, r.traversal)
+	shapes := map[string]string{"domain": "box", "nameserver": "ellipse", "ip": "diamond", "network": "component", "autnum": "hexagon", "entity": "note"}
+
+	fmt.Fprintf(w, "digraph rdapq {\n\trankdir=LR;\n")
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(w, "\t%v [label=%v, shape=%v, class=%v];\n", dotQuote(node.ID), dotQuote(node.Label), shapes[node.Type], dotQuote(node.Type))
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(w, "\t%v -> %v [label=%v];\n", dotQuote(edge.Source), dotQuote(edge.Target), dotQuote(edge.Relation))
+	}
+	_, err := fmt.Fprintf(w, "}\n")
+	return err
+}
+
+// Quote a DOT ID. Only the quote and backslash are escaped, the other
+// characters of a quoted string (including non-ASCII names) are taken as is.
+// https://graphviz.org/doc/info/lang.html#ids
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func dotQuote(value string) string {
+	return `"` + dotEscaper.Replace(value) + `"`
+}
+
+// GraphML with node type and label and edge relation attributes
+type graphmlRenderer struct {
+	traversal *Graph
+}
+
+func (r graphmlRenderer) Render(w io.Writer, results []Result) error {
+	graph := buildExportGraph(results, r.traversal)
+	escape := func(value string) string {
+		var escaped strings.Builder
+		xml.EscapeText(&escaped, []byte(value))
+		return escaped.String()
+	}
+
+	fmt.Fprintf(w, "%v", xml.Header)
+	fmt.Fprintf(w, "<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n")
+	fmt.Fprintf(w, "\t<key id=\"type\" for=\"node\" attr.name=\"type\" attr.type=\"string\"/>\n")
+	fmt.Fprintf(w, "\t<key id=\"label\" for=\"node\" attr.name=\"label\" attr.type=\"string\"/>\n")
+	fmt.Fprintf(w, "\t<key id=\"relation\" for=\"edge\" attr.name=\"relation\" attr.type=\"string\"/>\n")
+	fmt.Fprintf(w, "\t<graph id=\"rdapq\" edgedefault=\"directed\">\n")
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(w, "\t\t<node id=\"%v\"><data key=\"type\">%v</data><data key=\"label\">%v</data></node>\n", escape(node.ID), escape(node.Type), escape(node.Label))
+	}
+	for i, edge := range graph.Edges {
+		fmt.Fprintf(w, "\t\t<edge id=\"e%v\" source=\"%v\" target=\"%v\"><data key=\"relation\">%v</data></edge>\n", i, escape(edge.Source), escape(edge.Target), escape(edge.Relation))
+	}
+	fmt.Fprintf(w, "\t</graph>\n")
+	_, err := fmt.Fprintf(w, "</graphml>\n")
+	return err
+}
+
+// JSON node-link graph
+type graphJSONRenderer struct {
+	traversal *Graph
+}
+
+func (r graphJSONRenderer) Render(w io.Writer, results []Result) error {
+	graph := buildExportGraph(results, r.traversal)
+	nodeLink := struct {
+		Directed   bool           `json:"directed"`
+		Multigraph bool           `json:"multigraph"`
+		Graph      map[string]any `json:"graph"`
+		Nodes      []*exportNode  `json:"nodes"`
+		Links      []exportEdge   `json:"links"`
+	}{Directed: true, Multigraph: true, Graph: map[string]any{"name": "rdapq"}, Nodes: graph.Nodes, Links: graph.Edges}
+	if nodeLink.Nodes == nil {
+		nodeLink.Nodes = []*exportNode{}
+	}
+	if nodeLink.Links == nil {
+		nodeLink.Links = []exportEdge{}
+	}
+
+	output, err := json.MarshalIndent(nodeLink, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(output, '\n'))
+	return err
+}
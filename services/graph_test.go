@@ -0,0 +1,29 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+// The autnum node of a lookup answered with an ASN block is the queried ASN,
+// the same node an originating network links to
+func TestExportGraphAutnumBlock(t *testing.T) {
+	block := decodedResult(t, "AS64500", "autnum", `{"objectClassName": "autnum", "handle": "AS64496-AS64511", "startAutnum": 64496, "endAutnum": 64511, "name": "EXAMPLE-BLOCK"}`)
+	network := decodedResult(t, "192.0.2.1", "ip network", `{"objectClassName": "ip network", "handle": "NET-192-0-2-0-1",
+		"startAddress": "192.0.2.0", "endAddress": "192.0.2.255", "arin_originas0_originautnums": [64500]}`)
+	followed := decodedResult(t, "AS64496-AS64511", "autnum", `{"objectClassName": "autnum", "handle": "AS64496-AS64511", "startAutnum": 64496, "endAutnum": 64511}`)
+
+	graph := buildExportGraph([]Result{block, network, followed}, nil)
+
+	var nodes []string
+	for _, node := range graph.Nodes {
+		nodes = append(nodes, node.ID)
+	}
+	want := []string{"autnum:as64500", "ip:192.0.2.1", "network:net-192-0-2-0-1", "autnum:as64496"}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("nodes %q, want %q", nodes, want)
+	}
+	if edge := (exportEdge{Source: "network:net-192-0-2-0-1", Target: "autnum:as64500", Relation: "originated-by"}); !graph.edges[edge] {
+		t.Errorf("missing edge %+v in %+v", edge, graph.Edges)
+	}
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// IP network allocation chain, from the RIR block down to end-user
+// assignments
+//
+// Upward the walk follows "up" and "rdap-up" links, or when a network only
+// names its parentHandle, queries the prefix one bit shorter at the same
+// server. Downward it follows "rdap-down", "down" and "rdap-bottom" links
+// where the RIR publishes them.
+// https://datatracker.ietf.org/doc/html/rfc9910
+
+var (
+	hierarchyUpRels   = []string{"up", "rdap-up"}
+	hierarchyDownRels = []string{"rdap-down", "down", "rdap-bottom"}
+)
+
+// Return the results with the networks above and below each IP network
+// result, fetching at most budget objects for all of them, as Traverse does
+func NetworkHierarchy(results []Result, budget int) []Result {
+	var expanded []Result
+	requests := 0
+	for _, result := range results {
+		for _, network := range networkChain(result, budget, &requests) {
+			if network.IPNetwork == nil || !containsNetwork(expanded, *network.IPNetwork) {
+				expanded = append(expanded, network)
+			}
+		}
+	}
+	return expanded
+}
+
+// Return the networks above and below an IP network result, including the
+// result itself, counting the objects fetched in requests
+func networkChain(result Result, budget int, requests *int) []Result {
+	if result.IPNetwork == nil {
+		return []Result{result}
+	}
+
+	chain := []Result{result}
+	seen := []string{normalizeURL(result.ServerURL), normalizeURL(findLink(result.IPNetwork.Links, "self"))}
+
+	// Upward to the top allocation
+	current := result
+	for {
+		parentURL := firstNonEmpty(findRDAPLink(current.IPNetwork.Links, hierarchyUpRels), parentPrefixURL(current))
+		if parentURL == "" || slices.Contains(seen, normalizeURL(parentURL)) {
+			break
+		}
+		seen = append(seen, normalizeURL(parentURL))
+		if *requests >= budget {
+			fmt.Fprintf(Status, "\n(!) Request budget reached, not followed:\t%v", parentURL)
+			break
+		}
+
+		*requests++
+		fmt.Fprintf(Status, "\n(+) Querying parent network:\t%v", parentURL)
+		parents, _, err := fetchNetworks(parentURL)
+		if err != nil || len(parents) == 0 {
+			if err != nil {
+				fmt.Fprintf(Status, "\n(!) %v", err)
+			}
+			break
+		}
+		parent := parents[0]
+		if sameNetwork(*parent.IPNetwork, *current.IPNetwork) || containsNetwork(chain, *parent.IPNetwork) {
+			break
+		}
+		chain = append(chain, parent)
+		current = parent
+	}
+
+	// Downward from the queried network, page by page where the server
+	// advertises paging
+	for _, link := range result.IPNetwork.Links {
+		if !slices.Contains(hierarchyDownRels, link.Rel) || !isRDAPLink(link) {
+			continue
+		}
+		for pageURL := link.Href; pageURL != "" && !slices.Contains(seen, normalizeURL(pageURL)); {
+			seen = append(seen, normalizeURL(pageURL))
+			if *requests >= budget {
+				fmt.Fprintf(Status, "\n(!) Request budget reached, not followed:\t%v", pageURL)
+				break
+			}
+
+			*requests++
+			fmt.Fprintf(Status, "\n(+) Querying %v networks:\t%v", link.Rel, pageURL)
+			children, nextURL, err := fetchNetworks(pageURL)
+			if err != nil {
+				fmt.Fprintf(Status, "\n(!) %v", err)
+				break
+			}
+			for _, child := range children {
+				if !containsNetwork(chain, *child.IPNetwork) {
+					chain = append(chain, child)
+				}
+			}
+			pageURL = nextURL
+		}
+	}
+
+	sortNetworks(chain)
+	return chain
+}
+
+// Return the first RDAP link with one of the relations
+func findRDAPLink(links []m.Links, rels []string) string {
+	for _, link := range links {
+		if slices.Contains(rels, link.Rel) && isRDAPLink(link) {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// URL of the prefix one bit shorter than a network at the server it came
+// from, which returns the smallest network containing it
+func parentPrefixURL(result Result) string {
+	network := result.IPNetwork
+	if network.ParentHandle == "" {
+		return ""
+	}
+	base, _, found := strings.Cut(result.ServerURL, "/ip/")
+	if !found {
+		return ""
+	}
+
+	var summary Summary
+	summarizeNetwork(&summary, *network)
+	if len(summary.NetworkCIDR) == 0 {
+		return ""
+	}
+	prefix, err := netip.ParsePrefix(summary.NetworkCIDR[0])
+	if err != nil || prefix.Bits() == 0 {
+		return ""
+	}
+	parent := netip.PrefixFrom(prefix.Addr(), prefix.Bits()-1).Masked()
+	return base + "/ip/" + parent.String()
+}
+
+// Fetch an IP network, or the networks of an RFC 9910 search result along
+// with the URL of its next page
+func fetchNetworks(networkURL string) ([]Result, string, error) {
+	body, capabilities, err := queryRDAPServer(networkURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var response struct {
+		ObjectClassName      string            `json:"objectClassName"`
+		IPSearchResults      []json.RawMessage `json:"ipSearchResults"`
+		NetworkSearchResults []json.RawMessage `json:"networkSearchResults"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, "", fmt.Errorf("error un-marshalling %v:\n%v", networkURL, err)
+	}
+
+	objects := append(response.IPSearchResults, response.NetworkSearchResults...)
+	if response.ObjectClassName != "" {
+		objects = []json.RawMessage{body}
+	}
+
+	var results []Result
+	for _, object := range objects {
+		result := Result{ServerURL: networkURL, FetchedAt: time.Now().UTC(), Raw: object, Capabilities: capabilities}
+		if err := result.decode("ip network"); err != nil {
+			return nil, "", fmt.Errorf("%v: %v", networkURL, err)
+		}
+		result.Query = firstNonEmpty(result.IPNetwork.Handle, networkURL)
+		if len(objects) > 1 {
+			result.ServerURL = firstNonEmpty(findLink(result.IPNetwork.Links, "self"), networkURL)
+		}
+		results = append(results, result)
+	}
+	return results, nextPageURL(networkURL, body), nil
+}
+
+func networkRange(network m.IPNetwork) (netip.Addr, netip.Addr, bool) {
+	start, err := netip.ParseAddr(network.StartAddress)
+	if err != nil {
+		return start, start, false
+	}
+	end, err := netip.ParseAddr(network.EndAddress)
+	return start, end, err == nil
+}
+
+func sameNetwork(a m.IPNetwork, b m.IPNetwork) bool {
+	if a.Handle != "" && a.Handle == b.Handle {
+		return true
+	}
+	return a.StartAddress == b.StartAddress && a.EndAddress == b.EndAddress
+}
+
+func containsNetwork(results []Result, network m.IPNetwork) bool {
+	for _, result := range results {
+		if result.IPNetwork != nil && sameNetwork(*result.IPNetwork, network) {
+			return true
+		}
+	}
+	return false
+}
+
+// Order networks by start address, larger networks before the networks
+// they contain
+func sortNetworks(results []Result) {
+	slices.SortStableFunc(results, func(a Result, b Result) int {
+		aStart, aEnd, _ := networkRange(*a.IPNetwork)
+		bStart, bEnd, _ := networkRange(*b.IPNetwork)
+		if order := aStart.Compare(bStart); order != 0 {
+			return order
+		}
+		return bEnd.Compare(aEnd)
+	})
+}
+
+// Print the IP networks of the results indented by containment, marking the
+// networks of queried addresses
+func printAllocationChain(w io.Writer, results []Result) {
+	var chain []Result
+	for _, result := range results {
+		if result.IPNetwork != nil {
+			chain = append(chain, result)
+		}
+	}
+	sortNetworks(chain)
+
+	fmt.Fprintf(w, "\n\nAllocation Chain")
+	fmt.Fprintf(w, "\n---------------------------------------------------------------\n")
+
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	var parents []m.IPNetwork
+	for _, result := range chain {
+		network := *result.IPNetwork
+		start, end, _ := networkRange(network)
+
+		// Drop the parents that do not contain this network
+		for len(parents) > 0 {
+			parentStart, parentEnd, _ := networkRange(parents[len(parents)-1])
+			if parentStart.Compare(start) <= 0 && parentEnd.Compare(end) >= 0 {
+				break
+			}
+			parents = parents[:len(parents)-1]
+		}
+
+		marker := " "
+		if _, err := netip.ParseAddr(result.Query); err == nil {
+			marker = "*"
+		}
+		var summary Summary
+		summarizeNetwork(&summary, network)
+		fmt.Fprintf(table, "%v %v%v\t%v\t%v\t%v\t%v\n", marker, strings.Repeat("  ", len(parents)),
+			firstNonEmpty(strings.Join(summary.NetworkCIDR, ", "), network.StartAddress+" - "+network.EndAddress),
+			network.Handle, network.Name, network.Type, network.Country)
+		parents = append(parents, network)
+	}
+	table.Flush()
+}
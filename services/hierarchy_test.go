@@ -0,0 +1,173 @@
+package services
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A /24 linking up to a /20 that only names its parent, so the /8 above it
+// is found by querying the /19, and down to its assignments over two pages
+var hierarchyObjects = map[string]string{
+	"/ip/192.0.2.1": `{"objectClassName": "ip network", "handle": "NET-24", "startAddress": "192.0.2.0", "endAddress": "192.0.2.255", "type": "ASSIGNED PA", "links": [
+		{"rel": "self", "href": "BASE/ip/192.0.2.0/24"},
+		{"rel": "up", "href": "BASE/ip/parent"},
+		{"rel": "rdap-down", "href": "BASE/down"}
+	]}`,
+	"/ip/parent":       `{"objectClassName": "ip network", "handle": "NET-20", "startAddress": "192.0.0.0", "endAddress": "192.0.15.255", "parentHandle": "NET-8", "type": "ALLOCATED PA"}`,
+	"/ip/192.0.0.0/19": `{"objectClassName": "ip network", "handle": "NET-8", "startAddress": "192.0.0.0", "endAddress": "192.255.255.255", "type": "ALLOCATED"}`,
+	"/down": `{"rdapConformance": ["rdap_level_0", "paging"],
+		"ipSearchResults": [{"objectClassName": "ip network", "handle": "NET-26-0", "startAddress": "192.0.2.0", "endAddress": "192.0.2.63", "links": [{"rel": "self", "href": "BASE/ip/192.0.2.0/26"}]}],
+		"paging_metadata": {"links": [{"rel": "next", "href": "BASE/down/2"}]}}`,
+	"/down/2": `{"rdapConformance": ["rdap_level_0", "paging"],
+		"ipSearchResults": [{"objectClassName": "ip network", "handle": "NET-26-64", "startAddress": "192.0.2.64", "endAddress": "192.0.2.127", "country": "US"}]}`,
+	"/ip/198.51.100.1": `{"objectClassName": "ip network", "handle": "NET-OTHER", "startAddress": "198.51.100.0", "endAddress": "198.51.100.255", "links": [{"rel": "rdap-up", "href": "BASE/ip/other-parent"}]}`,
+}
+
+// Look up a network by address the way a query does
+func hierarchyStart(t *testing.T, serverURL string, address string) Result {
+	t.Helper()
+	results, _, err := fetchNetworks(serverURL + "/ip/" + address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results[0].Query = address
+	return results[0]
+}
+
+func handles(results []Result) []string {
+	var handles []string
+	for _, result := range results {
+		handles = append(handles, Summarize(result).Handle)
+	}
+	return handles
+}
+
+func TestNetworkHierarchy(t *testing.T) {
+	tests := []struct {
+		name      string
+		budget    int
+		handles   []string
+		requested []string
+	}{
+		{
+			name:      "up by link and parent prefix, down page by page",
+			budget:    20,
+			handles:   []string{"NET-8", "NET-20", "NET-24", "NET-26-0", "NET-26-64"},
+			requested: []string{"/ip/parent", "/ip/192.0.0.0/19", "/down", "/down/2"},
+		},
+		{
+			name:      "budget spent on the way up",
+			budget:    2,
+			handles:   []string{"NET-8", "NET-20", "NET-24"},
+			requested: []string{"/ip/parent", "/ip/192.0.0.0/19"},
+		},
+		{
+			name:      "budget spent before the last page",
+			budget:    3,
+			handles:   []string{"NET-8", "NET-20", "NET-24", "NET-26-0"},
+			requested: []string{"/ip/parent", "/ip/192.0.0.0/19", "/down"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, requested := rdapServer(t, hierarchyObjects)
+			start := hierarchyStart(t, server.URL, "192.0.2.1")
+
+			chain := NetworkHierarchy([]Result{start}, test.budget)
+			if got := handles(chain); !reflect.DeepEqual(got, test.handles) {
+				t.Errorf("chain %q, want %q", got, test.handles)
+			}
+			if got := requested()[1:]; !reflect.DeepEqual(got, test.requested) {
+				t.Errorf("requested %q, want %q", got, test.requested)
+			}
+		})
+	}
+}
+
+// One budget covers every queried network, later networks are not walked
+// once the earlier ones spent it
+func TestNetworkHierarchySharedBudget(t *testing.T) {
+	server, requested := rdapServer(t, hierarchyObjects)
+	first := hierarchyStart(t, server.URL, "192.0.2.1")
+	second := hierarchyStart(t, server.URL, "198.51.100.1")
+	domain := decodedResult(t, "example.com", "domain", renderDomain)
+
+	chain := NetworkHierarchy([]Result{first, domain, second}, 3)
+	if got, want := handles(chain), []string{"NET-8", "NET-20", "NET-24", "NET-26-0", "", "NET-OTHER"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("chain %q, want %q", got, want)
+	}
+	if got, want := requested()[2:], []string{"/ip/parent", "/ip/192.0.0.0/19", "/down"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("requested %q, want %q", got, want)
+	}
+}
+
+func TestParentPrefixURL(t *testing.T) {
+	tests := []struct {
+		serverURL string
+		network   string
+		want      string
+	}{
+		{
+			serverURL: "https://rdap.example/ip/192.0.2.1",
+			network:   `{"objectClassName": "ip network", "parentHandle": "P", "startAddress": "192.0.2.0", "endAddress": "192.0.2.255"}`,
+			want:      "https://rdap.example/ip/192.0.2.0/23",
+		},
+		{
+			serverURL: "https://rdap.example/rdap/ip/2001:db8::1",
+			network:   `{"objectClassName": "ip network", "parentHandle": "P", "startAddress": "2001:db8::", "endAddress": "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"}`,
+			want:      "https://rdap.example/rdap/ip/2001:db8::/31",
+		},
+		{
+			serverURL: "https://rdap.example/ip/192.0.2.1",
+			network:   `{"objectClassName": "ip network", "startAddress": "192.0.2.0", "endAddress": "192.0.2.255"}`,
+		},
+		{
+			serverURL: "https://rdap.example/ip/0.0.0.0",
+			network:   `{"objectClassName": "ip network", "parentHandle": "P", "startAddress": "0.0.0.0", "endAddress": "255.255.255.255"}`,
+		},
+		{
+			serverURL: "https://rdap.example/networks/NET-1",
+			network:   `{"objectClassName": "ip network", "parentHandle": "P", "startAddress": "192.0.2.0", "endAddress": "192.0.2.255"}`,
+		},
+	}
+
+	for _, test := range tests {
+		result := decodedResult(t, "192.0.2.1", "ip network", test.network)
+		result.ServerURL = test.serverURL
+		if got := parentPrefixURL(result); got != test.want {
+			t.Errorf("%v: got %q, want %q", test.serverURL, got, test.want)
+		}
+	}
+}
+
+func TestPrintAllocationChain(t *testing.T) {
+	server, _ := rdapServer(t, hierarchyObjects)
+	chain := NetworkHierarchy([]Result{hierarchyStart(t, server.URL, "192.0.2.1")}, 20)
+
+	var output bytes.Buffer
+	printAllocationChain(&output, chain)
+	lines := strings.Split(output.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+
+	// Columns line up whatever the depth of the network
+	want := strings.Join([]string{
+		"",
+		"",
+		"Allocation Chain",
+		"---------------------------------------------------------------",
+		"  192.0.0.0/8          NET-8        ALLOCATED",
+		"    192.0.0.0/20       NET-20       ALLOCATED PA",
+		"*     192.0.2.0/24     NET-24       ASSIGNED PA",
+		"        192.0.2.0/26   NET-26-0",
+		"        192.0.2.64/26  NET-26-64                  US",
+		"",
+	}, "\n")
+	if got := strings.Join(lines, "\n"); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	m "github.com/kadonnelly13/rdapq/models"
+	_ "modernc.org/sqlite"
+)
+
+// Local SQLite history of lookups. Every lookup keeps its raw response and
+// summary in "lookups"; "domains", "networks", "entities" and "events" hold
+// extracted fields for querying the database directly.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS lookups (
+	id          INTEGER PRIMARY KEY,
+	query       TEXT NOT NULL,
+	object_type TEXT NOT NULL,
+	handle      TEXT,
+	name        TEXT,
+	server      TEXT NOT NULL,
+	fetched_at  TEXT NOT NULL,
+	raw         TEXT NOT NULL,
+	summary     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS lookups_query ON lookups (query COLLATE NOCASE, fetched_at);
+
+CREATE TABLE IF NOT EXISTS domains (
+	lookup_id         INTEGER NOT NULL REFERENCES lookups (id),
+	ldh_name          TEXT,
+	unicode_name      TEXT,
+	registrar         TEXT,
+	registrar_iana_id TEXT,
+	registrant_org    TEXT,
+	created           TEXT,
+	updated           TEXT,
+	expires           TEXT,
+	statuses          TEXT,
+	nameservers       TEXT,
+	dnssec            INTEGER
+);
+CREATE INDEX IF NOT EXISTS domains_ldh_name ON domains (ldh_name COLLATE NOCASE);
+
+CREATE TABLE IF NOT EXISTS networks (
+	lookup_id     INTEGER NOT NULL REFERENCES lookups (id),
+	handle        TEXT,
+	name          TEXT,
+	start_address TEXT,
+	end_address   TEXT,
+	cidr          TEXT,
+	country       TEXT,
+	parent_handle TEXT
+);
+
+CREATE TABLE IF NOT EXISTS entities (
+	lookup_id INTEGER NOT NULL REFERENCES lookups (id),
+	handle    TEXT,
+	roles     TEXT,
+	name      TEXT,
+	email     TEXT,
+	country   TEXT
+);
+CREATE INDEX IF NOT EXISTS entities_email ON entities (email COLLATE NOCASE);
+
+CREATE TABLE IF NOT EXISTS events (
+	lookup_id     INTEGER NOT NULL REFERENCES lookups (id),
+	object_handle TEXT,
+	action        TEXT,
+	actor         TEXT,
+	date          TEXT
+);
+`
+
+// History database
+type History struct {
+	db *sql.DB
+}
+
+// Stored lookup
+type Snapshot struct {
+	ID         int64           `json:"id"`
+	Query      string          `json:"query"`
+	ObjectType string          `json:"objectType"`
+	Server     string          `json:"server"`
+	FetchedAt  time.Time       `json:"fetchedAt"`
+	Raw        json.RawMessage `json:"raw,omitempty"`
+	Summary    Summary         `json:"summary"`
+}
+
+// Open or create a history database
+func OpenHistory(location string) (*History, error) {
+	db, err := sql.Open("sqlite", historyDSN(location, ""))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating history database %v:\n%v", location, err)
+	}
+	return &History{db: db}, nil
+}
+
+// Open an existing history database read-only, so a mistyped path is
+// reported instead of creating an empty database
+func ReadHistory(location string) (*History, error) {
+	if _, err := os.Stat(location); err != nil {
+		return nil, fmt.Errorf("history database %v does not exist", location)
+	}
+	db, err := sql.Open("sqlite", historyDSN(location, "mode=ro"))
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error opening history database %v:\n%v", location, err)
+	}
+	return &History{db: db}, nil
+}
+
+// URI of a database file. The path is made absolute, since a relative one
+// would be read as the URI authority, and escaped so "?" and "#" in it are
+// not read as URI syntax.
+// https://www.sqlite.org/uri.html
+func historyDSN(location string, query string) string {
+	if absolute, err := filepath.Abs(location); err == nil {
+		location = absolute
+	}
+	DSN := url.URL{Scheme: "file", Path: filepath.ToSlash(location), RawQuery: query}
+	return DSN.String()
+}
+
+func (h *History) Close() error {
+	return h.db.Close()
+}
+
+// Store results, each in its own transaction
+func (h *History) Save(results []Result) error {
+	for _, result := range results {
+		if err := h.save(result); err != nil {
+			return fmt.Errorf("error saving %v to history:\n%v", result.Query, err)
+		}
+	}
+	return nil
+}
+
+func (h *History) save(result Result) error {
+	summary := Summarize(result)
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row, err := tx.Exec(`INSERT INTO lookups (query, object_type, handle, name, server, fetched_at, raw, summary) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		result.Query, summary.ObjectType, summary.Handle, summary.Name, result.ServerURL, result.FetchedAt.Format(time.RFC3339Nano), string(result.Raw), string(summaryJSON))
+	if err != nil {
+		return err
+	}
+	lookupID, err := row.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	var entities []m.Entity
+	var events []m.Events
+	var handle string
+
+	if domain := result.Domain; domain != nil {
+		_, err = tx.Exec(`INSERT INTO domains (lookup_id, ldh_name, unicode_name, registrar, registrar_iana_id, registrant_org, created, updated, expires, statuses, nameservers, dnssec) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			lookupID, domain.LdhName, domain.UnicodeName, summary.Registrar, summary.RegistrarIANAID, summary.RegistrantOrg, summary.Created, summary.Updated, summary.Expires,
+			strings.Join(summary.Statuses, ";"), strings.Join(summary.Nameservers, ";"), summary.DNSSEC)
+		if domain.Network != nil && err == nil {
+			err = saveNetwork(tx, lookupID, *domain.Network)
+		}
+		entities, events, handle = domain.Entities, domain.Events, domain.Handle
+	} else if network := result.IPNetwork; network != nil {
+		err = saveNetwork(tx, lookupID, *network)
+		entities, events, handle = network.Entities, network.Events, network.Handle
+	} else if autnum := result.Autonum; autnum != nil {
+		entities, events, handle = autnum.Entities, autnum.Events, autnum.Handle
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := saveEvents(tx, lookupID, handle, events); err != nil {
+		return err
+	}
+	if err := saveEntities(tx, lookupID, entities); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func saveNetwork(tx *sql.Tx, lookupID int64, network m.IPNetwork) error {
+	var summary Summary
+	summarizeNetwork(&summary, network)
+	_, err := tx.Exec(`INSERT INTO networks (lookup_id, handle, name, start_address, end_address, cidr, country, parent_handle) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		lookupID, network.Handle, network.Name, network.StartAddress, network.EndAddress, strings.Join(summary.NetworkCIDR, ";"), network.Country, network.ParentHandle)
+	return err
+}
+
+// Store entities, their events and nested entities
+func saveEntities(tx *sql.Tx, lookupID int64, entities []m.Entity) error {
+	for _, entity := range entities {
+		_, err := tx.Exec(`INSERT INTO entities (lookup_id, handle, roles, name, email, country) VALUES (?, ?, ?, ?, ?, ?)`,
+			lookupID, entity.Handle, strings.Join(entity.Roles, ";"), entityName(entity), vcardText(entity, "email"), entityCountry(entity))
+		if err != nil {
+			return err
+		}
+		if err := saveEvents(tx, lookupID, entity.Handle, entity.Events); err != nil {
+			return err
+		}
+		if err := saveEntities(tx, lookupID, entity.Entities); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveEvents(tx *sql.Tx, lookupID int64, handle string, events []m.Events) error {
+	for _, event := range events {
+		_, err := tx.Exec(`INSERT INTO events (lookup_id, object_handle, action, actor, date) VALUES (?, ?, ?, ?, ?)`,
+			lookupID, handle, event.EventAction, event.EventActor, event.EventDate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return the stored lookups of an indicator, oldest first
+func (h *History) Snapshots(query string) ([]Snapshot, error) {
+	rows, err := h.db.Query(`SELECT id, query, object_type, server, fetched_at, summary FROM lookups WHERE query = ? COLLATE NOCASE ORDER BY fetched_at, id`, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var snapshot Snapshot
+		var fetchedAt, summary string
+		if err := rows.Scan(&snapshot.ID, &snapshot.Query, &snapshot.ObjectType, &snapshot.Server, &fetchedAt, &summary); err != nil {
+			return nil, err
+		}
+		if err := snapshot.decode(fetchedAt, summary); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// Return one stored lookup including its raw response
+func (h *History) Snapshot(id int64) (Snapshot, error) {
+	var snapshot Snapshot
+	var fetchedAt, raw, summary string
+
+	err := h.db.QueryRow(`SELECT id, query, object_type, server, fetched_at, raw, summary FROM lookups WHERE id = ?`, id).
+		Scan(&snapshot.ID, &snapshot.Query, &snapshot.ObjectType, &snapshot.Server, &fetchedAt, &raw, &summary)
+	if err == sql.ErrNoRows {
+		return snapshot, fmt.Errorf("no snapshot with id %v", id)
+	} else if err != nil {
+		return snapshot, err
+	}
+
+	snapshot.Raw = json.RawMessage(raw)
+	return snapshot, snapshot.decode(fetchedAt, summary)
+}
+
+// Decode the stored fetch time and summary of a snapshot
+func (snapshot *Snapshot) decode(fetchedAt string, summary string) error {
+	var err error
+	if snapshot.FetchedAt, err = time.Parse(time.RFC3339Nano, fetchedAt); err != nil {
+		return fmt.Errorf("snapshot %v has an invalid fetch time: %v", snapshot.ID, err)
+	}
+	if err := json.Unmarshal([]byte(summary), &snapshot.Summary); err != nil {
+		return fmt.Errorf("snapshot %v has an invalid summary: %v", snapshot.ID, err)
+	}
+	return nil
+}
+
+// Rebuild the lookup result of a stored snapshot
+func (snapshot Snapshot) Result() (Result, error) {
+	result := Result{Query: snapshot.Query, ServerURL: snapshot.Server, FetchedAt: snapshot.FetchedAt, Raw: snapshot.Raw, Capabilities: snapshot.Summary.Capabilities}
+	err := result.decode(snapshot.ObjectType)
+	return result, err
+}
+
+// Print stored lookups as a table
+func PrintSnapshots(w io.Writer, snapshots []Snapshot) {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(table, "ID\tFetched\tServer\tRegistrar\tExpires\tStatuses\tNameservers\n")
+	for _, snapshot := range snapshots {
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			snapshot.ID, snapshot.FetchedAt.Format(time.RFC3339), snapshot.Server, snapshot.Summary.Registrar, snapshot.Summary.Expires,
+			strings.Join(snapshot.Summary.Statuses, ", "), strings.Join(snapshot.Summary.Nameservers, ", "))
+	}
+	table.Flush()
+}
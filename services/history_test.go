@@ -0,0 +1,46 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Paths holding URI syntax open the file they name, read-write and read-only
+func TestHistoryPaths(t *testing.T) {
+	directory := t.TempDir()
+	t.Chdir(directory)
+
+	for _, location := range []string{filepath.Join(directory, "a?b#c.db"), "relative?mode=rw.db", "space %41.db"} {
+		t.Run(location, func(t *testing.T) {
+			history, err := OpenHistory(location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := history.Save(sinkResults("example.com")); err != nil {
+				t.Fatal(err)
+			}
+			history.Close()
+			if _, err := os.Stat(location); err != nil {
+				t.Fatalf("database not created at %v: %v", location, err)
+			}
+
+			history, err = ReadHistory(location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer history.Close()
+			snapshots, err := history.Snapshots("example.com")
+			if err != nil || len(snapshots) != 1 {
+				t.Errorf("%v snapshots, error %v", len(snapshots), err)
+			}
+			if err := history.Save(sinkResults("example.net")); err == nil {
+				t.Error("saved to a database opened read-only")
+			}
+		})
+	}
+
+	if _, err := ReadHistory(filepath.Join(directory, "missing.db")); err == nil {
+		t.Error("no error reading a missing database")
+	}
+}
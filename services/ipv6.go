@@ -0,0 +1,49 @@
+package services
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Look up an IPv6 address at its authoritative server
+func LookupIPv6(ipv6 string, registryURL string) (Result, error) {
+	address, err := netip.ParseAddr(ipv6)
+	if err != nil || !address.Is6() {
+		return Result{}, fmt.Errorf("error parsing IPv6 address %q", ipv6)
+	}
+	URL, err := getAuthoritativeIPv6ServerURL(address, registryURL)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return queryAuthoritativeIPServer(ipv6, URL+"ip/"+address.String())
+}
+
+// IPv6 entries are prefixes of any length, the longest one containing the
+// address wins
+// https://datatracker.ietf.org/doc/html/rfc9224#section-5.2
+func getAuthoritativeIPv6ServerURL(address netip.Addr, registryURL string) (string, error) {
+	fmt.Fprintf(Status, "\n(+) Finding Authoritative Service URL for:\t%v", address)
+
+	bootstrapRegistryData, err := getBootstrapRegistry(registryURL)
+	if err != nil {
+		return "", err
+	}
+
+	var URL string
+	bits := -1
+	for _, service := range bootstrapRegistryData.Services {
+		for _, serviceV6 := range service[0] {
+			prefix, err := netip.ParsePrefix(serviceV6)
+			if err == nil && prefix.Contains(address) && prefix.Bits() > bits {
+				URL, bits = service[1][0], prefix.Bits()
+			}
+		}
+	}
+	if URL == "" {
+		return "", fmt.Errorf("no RDAP service found for %v", address)
+	}
+
+	fmt.Fprintf(Status, "\n(+) Service URL for '%v':\t\t%v", address, URL)
+	return URL, nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MISP event export
+// https://www.misp-project.org/datamodels/
+//
+// Domains are written as "whois" objects, IP networks as "ip-port" objects
+// referencing an "asn" object for each origin ASN, and autnums as "asn"
+// objects. Objects name their template and MISP resolves it on import.
+
+type mispAttribute struct {
+	UUID           string `json:"uuid"`
+	ObjectRelation string `json:"object_relation"`
+	Type           string `json:"type"`
+	Category       string `json:"category"`
+	Value          string `json:"value"`
+	ToIDS          bool   `json:"to_ids"`
+	Comment        string `json:"comment,omitempty"`
+}
+
+type mispReference struct {
+	ReferencedUUID   string `json:"referenced_uuid"`
+	RelationshipType string `json:"relationship_type"`
+}
+
+type mispObject struct {
+	UUID            string          `json:"uuid"`
+	Name            string          `json:"name"`
+	MetaCategory    string          `json:"meta-category"`
+	Distribution    string          `json:"distribution"`
+	Comment         string          `json:"comment,omitempty"`
+	Attribute       []mispAttribute `json:"Attribute"`
+	ObjectReference []mispReference `json:"ObjectReference,omitempty"`
+}
+
+type mispEvent struct {
+	UUID          string       `json:"uuid"`
+	Info          string       `json:"info"`
+	Date          string       `json:"date"`
+	ThreatLevelID string       `json:"threat_level_id"`
+	Analysis      string       `json:"analysis"`
+	Distribution  string       `json:"distribution"`
+	Object        []mispObject `json:"Object"`
+}
+
+// MISP event holding one object per result
+type mispRenderer struct{}
+
+func (mispRenderer) Render(w io.Writer, results []Result) error {
+	var queries []string
+	objects := []mispObject{}
+
+	for _, result := range results {
+		if !slices.Contains(queries, result.Query) {
+			queries = append(queries, result.Query)
+		}
+		if result.Domain != nil {
+			objects = append(objects, mispWhoisObject(result))
+		} else if result.IPNetwork != nil {
+			objects = append(objects, mispIPObjects(result)...)
+		} else if result.Autonum != nil {
+			objects = append(objects, mispASNObject(queriedASN(result), result))
+		}
+	}
+
+	event := mispEvent{
+		UUID:          uuid4(),
+		Info:          "rdapq RDAP lookup: " + strings.Join(queries, ", "),
+		Date:          time.Now().UTC().Format("2006-01-02"),
+		ThreatLevelID: "4",
+		Analysis:      "2",
+		Distribution:  "0",
+		Object:        objects,
+	}
+
+	output, err := json.MarshalIndent(map[string]mispEvent{"Event": event}, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(output, '\n'))
+	return err
+}
+
+// Append an attribute when it has a value
+func (object *mispObject) add(relation string, attributeType string, category string, value string) {
+	if value == "" {
+		return
+	}
+	object.Attribute = append(object.Attribute, mispAttribute{
+		UUID:           uuid4(),
+		ObjectRelation: relation,
+		Type:           attributeType,
+		Category:       category,
+		Value:          value,
+	})
+}
+
+// whois object for a domain
+func mispWhoisObject(result Result) mispObject {
+	domain := result.Domain
+	summary := Summarize(result)
+	object := mispObject{UUID: uuid4(), Name: "whois", MetaCategory: "network", Distribution: "5", Comment: "RDAP " + result.ServerURL}
+
+	object.add("domain", "domain", "Network activity", strings.ToLower(domain.LdhName))
+	object.add("registrar", "whois-registrar", "Attribution", summary.Registrar)
+	object.add("registrant-org", "whois-registrant-org", "Attribution", summary.RegistrantOrg)
+	if registrant := findEntity(domain.Entities, "registrant"); registrant != nil {
+		object.add("registrant-name", "whois-registrant-name", "Attribution", vcardText(*registrant, "fn"))
+		object.add("registrant-email", "whois-registrant-email", "Attribution", vcardText(*registrant, "email"))
+		object.add("registrant-phone", "whois-registrant-phone", "Attribution", vcardText(*registrant, "tel"))
+	}
+	object.add("creation-date", "datetime", "Other", summary.Created)
+	object.add("modification-date", "datetime", "Other", summary.Updated)
+	object.add("expiration-date", "datetime", "Other", summary.Expires)
+	for _, nameserver := range summary.Nameservers {
+		object.add("nameserver", "hostname", "Network activity", nameserver)
+	}
+	if len(summary.Statuses) > 0 {
+		object.add("text", "text", "Other", "Status: "+strings.Join(summary.Statuses, ", "))
+	}
+
+	return object
+}
+
+// ip-port object for an IP lookup and asn objects for its origin ASNs
+func mispIPObjects(result Result) []mispObject {
+	network := result.IPNetwork
+	summary := Summarize(result)
+	object := mispObject{UUID: uuid4(), Name: "ip-port", MetaCategory: "network", Distribution: "5", Comment: "RDAP " + result.ServerURL}
+
+	object.add("ip", "ip-dst", "Network activity", result.Query)
+	var description []string
+	for _, value := range []string{network.Name, network.Handle, strings.Join(summary.NetworkCIDR, ", "), summary.RegistrantOrg, network.Country} {
+		if value != "" {
+			description = append(description, value)
+		}
+	}
+	object.add("text", "text", "Other", strings.Join(description, " / "))
+
+	objects := []mispObject{object}
+	for _, asn := range originAutnums(*network) {
+		asnObject := mispASNObject(asn, result)
+		for _, cidr := range summary.NetworkCIDR {
+			asnObject.add("subnet-announced", "ip-src", "Network activity", cidr)
+		}
+		objects[0].ObjectReference = append(objects[0].ObjectReference, mispReference{ReferencedUUID: asnObject.UUID, RelationshipType: "belongs-to"})
+		objects = append(objects, asnObject)
+	}
+	return objects
+}
+
+// asn object, described from the autnum record when the result has one
+func mispASNObject(asn uint32, result Result) mispObject {
+	object := mispObject{UUID: uuid4(), Name: "asn", MetaCategory: "network", Distribution: "5", Comment: "RDAP " + result.ServerURL}
+
+	object.add("asn", "AS", "Network activity", "AS"+strconv.FormatUint(uint64(asn), 10))
+	if autnum := result.Autonum; autnum != nil {
+		object.add("description", "text", "Other", autnum.Name)
+		object.add("country", "text", "Other", autnum.Country)
+		if registrant := findEntity(autnum.Entities, "registrant"); registrant != nil {
+			object.add("text", "text", "Other", entityName(*registrant))
+		}
+	}
+	return object
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Shared printers for optional RFC 9083 members. Every member may be absent
+// from a response so nothing here indexes into a slice without a bounds check.
+
+// Return the href of the first link with a matching relation type
+func findLink(links []m.Links, rel string) string {
+	for _, link := range links {
+		if link.Rel == rel && link.Href != "" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// Return where the object was served from, preferring the "self" link
+func dataSource(links []m.Links) string {
+	if href := findLink(links, "self"); href != "" {
+		return href
+	}
+	for _, link := range links {
+		if link.Value != "" {
+			return link.Value
+		}
+	}
+	return ""
+}
+
+// Print object events
+func printEvents(w io.Writer, title string, events []m.Events) {
+	fmt.Fprintf(w, "\n\n%v", title)
+	for _, event := range events {
+		fmt.Fprintf(w, "\n\n\tAction:\t\t%v", event.EventAction)
+		fmt.Fprintf(w, "\n\tDate:\t\t%v", event.EventDate)
+		if event.EventActor != "" {
+			fmt.Fprintf(w, "\n\tActor:\t\t%v", event.EventActor)
+		}
+	}
+}
+
+// Print the derived triage facts and risk score of a domain
+func printRisk(w io.Writer, risk DomainRisk) {
+	days := func(value *int) string {
+		if value == nil {
+			return "unknown"
+		}
+		return fmt.Sprint(*value)
+	}
+
+	fmt.Fprintf(w, "\n\nRisk")
+	fmt.Fprintf(w, "\n\n\tScore:\t\t%v/100", risk.Score)
+	fmt.Fprintf(w, "\n\tAge (days):\t%v", days(risk.AgeDays))
+	fmt.Fprintf(w, "\n\tSince Change:\t%v", days(risk.DaysSinceChange))
+	fmt.Fprintf(w, "\n\tTo Expiry:\t%v", days(risk.DaysToExpiry))
+	fmt.Fprintf(w, "\n\tPeriod (days):\t%v", days(risk.RegistrationPeriodDays))
+	fmt.Fprintf(w, "\n\tPrivacy/Proxy:\t%v", risk.PrivacyProxy)
+	fmt.Fprintf(w, "\n\tDNSSEC:\t\t%v", risk.DNSSEC)
+	for _, reason := range risk.Reasons {
+		fmt.Fprintf(w, "\n\tReason:\t\t%v", reason)
+	}
+}
+
+// Print notices with every description line and link
+func printNotices(w io.Writer, notices []m.Notices) {
+	fmt.Fprintf(w, "\n\nNotices")
+	for _, notice := range notices {
+		fmt.Fprintf(w, "\n\n\tTitle:\t\t%v", notice.Title)
+		for _, description := range notice.Descriptions {
+			fmt.Fprintf(w, "\n\tDescription:\t%v", description)
+		}
+		for _, link := range notice.Links {
+			if link.Href != "" {
+				fmt.Fprintf(w, "\n\tLink:\t\t%v", link.Href)
+			}
+		}
+	}
+}
+
+// Print entities and their vCard data
+func printEntities(w io.Writer, entities []m.Entity) {
+	for _, entity := range entities {
+		fmt.Fprintf(w, "\n\nEntities:")
+		for _, publicId := range entity.PublicIds {
+			fmt.Fprintf(w, "\n\t%v: %v", publicId.Type, publicId.Identifier)
+		}
+		fmt.Fprintf(w, "\n\tHandle: %v", entity.Handle)
+		fmt.Fprintf(w, "\n\tRole: %v", strings.Join(entity.Roles, ", "))
+		fmt.Fprintf(w, "\n\tvCard Data:")
+
+		/*
+			To-Do
+			Better print out vCard data
+		*/
+		for _, vcard := range entity.VcardArray {
+			if vcard != "vcard" {
+				switch data := vcard.(type) {
+				case []interface{}:
+					for _, d := range data {
+						fmt.Fprintf(w, "\n\t\t%+v", d)
+					}
+				}
+				fmt.Fprintf(w, "\n")
+			}
+		}
+		if card, found := entity.Extensions["jscard"]; found {
+			fmt.Fprintf(w, "\n\tJSContact Data:\n\t\t%s\n", card)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Sparse responses omit any optional member; the printers must not panic on
+// them and must still print the sections they always print
+func TestPrintSparseResponses(t *testing.T) {
+	tests := []struct {
+		name  string
+		print func(w io.Writer)
+		want  []string
+	}{
+		{
+			name:  "empty domain",
+			print: func(w io.Writer) { prettyPrintDomainData(w, m.Domain{}) },
+			want:  []string{"RDAP Query Results", "RDAP Data Source: \n", "Nameservers:", "Domain Statuses", "DNSSEC", "Latest DNS Events", "Notices"},
+		},
+		{
+			name: "domain without events, notices or links",
+			print: func(w io.Writer) {
+				prettyPrintDomainData(w, m.Domain{LdhName: "EXAMPLE.COM", Nameservers: []m.Nameserver{{LdhName: "A.IANA-SERVERS.NET"}}})
+			},
+			want: []string{"Domain: EXAMPLE.COM", "LDH Name: A.IANA-SERVERS.NET", "To Expiry:\tunknown"},
+		},
+		{
+			name: "domain data source from link value",
+			print: func(w io.Writer) {
+				prettyPrintDomainData(w, m.Domain{Links: []m.Links{{Value: "https://rdap.example/domain/example.com", Rel: "related"}}})
+			},
+			want: []string{"RDAP Data Source: https://rdap.example/domain/example.com"},
+		},
+		{
+			name:  "empty network",
+			print: func(w io.Writer) { prettyPrintIPData(w, m.IPNetwork{}) },
+			want:  []string{"RDAP Query Results", "IP Range:", "Statuses", "Latest Events", "Notices"},
+		},
+		{
+			name: "network with an event and an empty notice",
+			print: func(w io.Writer) {
+				prettyPrintIPData(w, m.IPNetwork{Handle: "NET-93-184-216-0-1", Events: []m.Events{{EventAction: "last changed"}}, Notices: []m.Notices{{}}})
+			},
+			want: []string{"IP Range:\t\tNET-93-184-216-0-1", "Action:\t\tlast changed", "Title:\t\t"},
+		},
+		{
+			name:  "empty autnum",
+			print: func(w io.Writer) { prettyPrintAutonumData(w, m.Autonum{}) },
+			want:  []string{"RDAP Query Results", "AS Handle:", "Start Autnum:\t\t0", "Notices"},
+		},
+		{
+			name:  "entity without vCard",
+			print: func(w io.Writer) { printEntities(w, []m.Entity{{Handle: "376", Roles: []string{"registrar"}}}) },
+			want:  []string{"Handle: 376", "Role: registrar", "vCard Data:"},
+		},
+		{
+			name:  "entity with an empty vCard",
+			print: func(w io.Writer) { printEntities(w, []m.Entity{{VcardArray: []interface{}{"vcard"}}}) },
+			want:  []string{"Entities:", "vCard Data:"},
+		},
+		{
+			name: "entity with vCard properties",
+			print: func(w io.Writer) {
+				printEntities(w, []m.Entity{{VcardArray: []interface{}{"vcard", []interface{}{[]interface{}{"fn", map[string]interface{}{}, "text", "Example Registrar"}}}}})
+			},
+			want: []string{"Example Registrar"},
+		},
+		{
+			name: "nil and empty nested entities",
+			print: func(w io.Writer) {
+				printEntities(w, []m.Entity{{Handle: "A", Entities: nil}, {Handle: "B", Entities: []m.Entity{{}}}})
+			},
+			want: []string{"Handle: A", "Handle: B"},
+		},
+		{
+			name:  "no entities",
+			print: func(w io.Writer) { printEntities(w, nil) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			test.print(&output)
+			for _, want := range test.want {
+				if !strings.Contains(output.String(), want) {
+					t.Errorf("output does not contain %q:\n%v", want, output.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// What an RDAP server supports: its help response, conformance, transport
+// behaviour, CORS and which searches it answers
+// https://datatracker.ietf.org/doc/html/rfc7480
+// https://datatracker.ietf.org/doc/html/rfc9082#section-3.2
+
+// Capability report of one server
+type ProbeReport struct {
+	Target       string       `json:"target"`
+	BaseURL      string       `json:"baseUrl"`
+	HTTPS        bool         `json:"https"`
+	PlainHTTP    string       `json:"plainHttp,omitempty"`
+	CORS         string       `json:"cors,omitempty"`
+	Conformance  []string     `json:"rdapConformance"`
+	Capabilities []string     `json:"capabilities"`
+	Checks       []ProbeCheck `json:"checks"`
+}
+
+// One request made by a probe. Redirects are reported, not followed.
+type ProbeCheck struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	StatusCode  int    `json:"statusCode,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Elapsed     int64  `json:"elapsedMs"`
+	Supported   bool   `json:"supported"`
+	Error       string `json:"error,omitempty"`
+
+	body []byte
+	cors string
+}
+
+// Searches probed at every server, by path and query below the base URL
+// https://datatracker.ietf.org/doc/html/rfc9082#section-3.2
+// https://datatracker.ietf.org/doc/html/rfc9536#section-2
+// https://datatracker.ietf.org/doc/html/rfc9910#section-3
+var probeSearches = [][2]string{
+	{"domain search", "domains?name=example*"},
+	{"nameserver search", "nameservers?name=ns1.example*"},
+	{"entity search", "entities?fn=example*"},
+	{"entity handle search", "entities?handle=example*"},
+	{"reverse search", "domains/reverse_search/entity?fn=example*"},
+	{"ip search", "ips?handle=example*"},
+	{"autnum search", "autnums?handle=example*"},
+}
+
+// Redirects are part of the report, so the probe client does not follow them
+var probeClient = &http.Client{
+	Timeout: 30 * time.Second,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Resolve a probe target to a base URL: a URL as given, an ASN ("AS"
+// prefixed), an IP address or prefix, or a TLD, found in the bootstrap
+// registries. The lookup path probed for the target is returned with it.
+func ProbeBaseURL(target string, registryBaseURL string) (string, string, error) {
+	if strings.HasPrefix(target, "https://") || strings.HasPrefix(target, "http://") {
+		return strings.TrimSuffix(target, "/") + "/", "", nil
+	}
+	if strings.HasPrefix(strings.ToUpper(target), "AS") {
+		if number, err := parseASN(target); err == nil {
+			URL, err := getAuthoritativeASNServerURL(number, registryBaseURL+"asn.json")
+			return URL, "autnum/" + strconv.FormatUint(uint64(number), 10), err
+		}
+	}
+
+	address, err := netip.ParseAddr(target)
+	if prefix, prefixErr := netip.ParsePrefix(target); prefixErr == nil {
+		address, err = prefix.Addr(), nil
+	}
+	if err == nil {
+		var URL string
+		if address.Is4() {
+			fullIPv4, err := getFullIPv4(address.String())
+			if err != nil {
+				return "", "", err
+			}
+			URL, err = getAuthoritativeIPServerURL(fullIPv4, registryBaseURL+"ipv4.json")
+			if err != nil {
+				return "", "", err
+			}
+		} else {
+			URL, err = getAuthoritativeIPv6ServerURL(address, registryBaseURL+"ipv6.json")
+			if err != nil {
+				return "", "", err
+			}
+		}
+		return URL, "ip/" + target, nil
+	}
+
+	TLD := strings.Trim(target, ".")
+	if strings.Contains(TLD, ".") {
+		TLD = parseDomain(TLD)
+	}
+	URL, err := getAuthoritativeDomainServerURL(TLD, registryBaseURL+"dns.json")
+	return URL, "domain/nic." + TLD, err
+}
+
+// Probe a server: its help response, plain HTTP behaviour, a lookup of the
+// target and each search
+func Probe(target string, baseURL string, lookupPath string) ProbeReport {
+	report := ProbeReport{Target: target, BaseURL: baseURL, HTTPS: strings.HasPrefix(baseURL, "https://")}
+
+	fmt.Fprintf(Status, "\n(+) Probing RDAP server:\t%v", baseURL)
+	help := probeRequest("help", baseURL+"help")
+	report.Checks = append(report.Checks, help)
+	report.CORS = help.cors
+
+	if report.HTTPS {
+		plain := probeRequest("plain http", "http://"+strings.TrimPrefix(baseURL, "https://")+"help")
+		report.Checks = append(report.Checks, plain)
+		report.PlainHTTP = plainHTTPBehaviour(plain)
+	}
+
+	var conformanceSource []byte
+	if help.Supported {
+		conformanceSource = help.body
+	}
+	if lookupPath != "" {
+		lookup := probeRequest("lookup", baseURL+lookupPath)
+		report.Checks = append(report.Checks, lookup)
+		if conformanceSource == nil && lookup.Supported {
+			conformanceSource = lookup.body
+		}
+	}
+	for _, search := range probeSearches {
+		report.Checks = append(report.Checks, probeRequest(search[0], baseURL+search[1]))
+	}
+
+	var response struct {
+		RdapConformance []string `json:"rdapConformance"`
+	}
+	json.Unmarshal(conformanceSource, &response)
+	report.Conformance = append([]string{}, response.RdapConformance...)
+	report.Capabilities = append([]string{}, Capabilities(response.RdapConformance)...)
+	return report
+}
+
+// Classify the answer to a plain HTTP request by its status code. Any 2xx
+// answer is served without TLS, whether or not the body is RDAP.
+func plainHTTPBehaviour(plain ProbeCheck) string {
+	switch {
+	case plain.Error != "":
+		return "not served"
+	case plain.StatusCode >= 300 && plain.StatusCode <= 399 && strings.HasPrefix(strings.ToLower(plain.Location), "https://"):
+		return "redirects to HTTPS"
+	case plain.StatusCode >= 300 && plain.StatusCode <= 399:
+		return "redirects without TLS"
+	case plain.StatusCode >= 200 && plain.StatusCode <= 299:
+		return "served without TLS"
+	}
+	return fmt.Sprintf("refused (status %v)", plain.StatusCode)
+}
+
+// Make one timed request. A check is supported when it returns 200 with a
+// JSON body.
+func probeRequest(name string, URL string) ProbeCheck {
+	check := ProbeCheck{Name: name, URL: URL}
+
+	request, err := http.NewRequest("GET", URL, nil)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	request.Header.Set("Accept", "application/rdap+json")
+	request.Header.Set("Origin", "https://example.org")
+
+	start := time.Now()
+	response, err := probeClient.Do(request)
+	if err != nil {
+		check.Elapsed = time.Since(start).Milliseconds()
+		check.Error = err.Error()
+		return check
+	}
+	check.body, err = io.ReadAll(response.Body)
+	response.Body.Close()
+	check.Elapsed = time.Since(start).Milliseconds()
+	if err != nil {
+		check.Error = err.Error()
+	}
+
+	check.StatusCode = response.StatusCode
+	check.ContentType = response.Header.Get("Content-Type")
+	check.Location = response.Header.Get("Location")
+	check.cors = response.Header.Get("Access-Control-Allow-Origin")
+	check.Supported = response.StatusCode == 200 && json.Valid(check.body)
+	return check
+}
+
+// Print probe reports with one row per check
+func PrintProbeReports(w io.Writer, reports []ProbeReport) {
+	for _, report := range reports {
+		fmt.Fprintf(w, "\nRDAP Server Probe: %v", report.Target)
+		fmt.Fprintf(w, "\n---------------------------------------------------------------")
+		fmt.Fprintf(w, "\nBase URL:\t\t%v", report.BaseURL)
+		fmt.Fprintf(w, "\nHTTPS:\t\t\t%v", report.HTTPS)
+		if report.PlainHTTP != "" {
+			fmt.Fprintf(w, "\nPlain HTTP:\t\t%v", report.PlainHTTP)
+		}
+		fmt.Fprintf(w, "\nCORS:\t\t\t%v", firstNonEmpty(report.CORS, "no Access-Control-Allow-Origin header"))
+		fmt.Fprintf(w, "\nConformance:\t\t%v", firstNonEmpty(strings.Join(report.Conformance, ", "), "none advertised"))
+		fmt.Fprintf(w, "\nCapabilities:\t\t%v\n\n", firstNonEmpty(strings.Join(report.Capabilities, ", "), "none advertised"))
+
+		table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(table, "Check\tSupported\tStatus\tTime\tContent Type\tURL\n")
+		for _, check := range report.Checks {
+			status := strconv.Itoa(check.StatusCode)
+			if check.Error != "" {
+				status = "error"
+			} else if check.Location != "" {
+				status += " -> " + check.Location
+			}
+			fmt.Fprintf(table, "%v\t%v\t%v\t%vms\t%v\t%v\n", check.Name, check.Supported, status, check.Elapsed, check.ContentType, check.URL)
+		}
+		table.Flush()
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// Write probe reports as a JSON array
+func WriteProbeReportsJSON(w io.Writer, reports []ProbeReport) error {
+	if reports == nil {
+		reports = []ProbeReport{}
+	}
+	output, err := json.MarshalIndent(reports, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(output, '\n'))
+	return err
+}
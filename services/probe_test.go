@@ -0,0 +1,194 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Server answering each probe path the way a misconfigured or partial RDAP
+// deployment might
+func probeServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	quietStatus(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/rdap+json" || r.Header.Get("Origin") != "https://example.org" {
+			http.Error(w, "missing probe headers", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/help":
+			w.Header().Set("Content-Type", "application/rdap+json")
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			io.WriteString(w, `{"rdapConformance": ["rdap_level_0"]}`)
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, "<html>Welcome</html>")
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/secure":
+			http.Redirect(w, r, "HTTPS://rdap.example/help", http.StatusMovedPermanently)
+		case "/moved":
+			http.Redirect(w, r, "http://rdap.example/help", http.StatusFound)
+		case "/relative":
+			http.Redirect(w, r, "/help", http.StatusTemporaryRedirect)
+		case "/broken":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProbeRequest(t *testing.T) {
+	server := probeServer(t)
+
+	help := probeRequest("help", server.URL+"/help")
+	if !help.Supported || help.StatusCode != 200 || help.ContentType != "application/rdap+json" || help.cors != "*" || help.Error != "" {
+		t.Errorf("help: got %+v", help)
+	}
+
+	// A 200 answer that is not JSON is not RDAP
+	if page := probeRequest("page", server.URL+"/page"); page.Supported || page.StatusCode != 200 {
+		t.Errorf("page: got %+v", page)
+	}
+
+	// Redirects are reported with their target and not followed
+	moved := probeRequest("moved", server.URL+"/moved")
+	if moved.Supported || moved.StatusCode != http.StatusFound || moved.Location != "http://rdap.example/help" {
+		t.Errorf("moved: got %+v", moved)
+	}
+
+	server.Close()
+	if closed := probeRequest("closed", server.URL+"/help"); closed.Error == "" || closed.StatusCode != 0 || closed.Supported {
+		t.Errorf("closed: got %+v", closed)
+	}
+}
+
+// Plain HTTP is classified by the status code, whatever the body
+func TestPlainHTTPBehaviour(t *testing.T) {
+	server := probeServer(t)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/secure", want: "redirects to HTTPS"},
+		{path: "/moved", want: "redirects without TLS"},
+		{path: "/relative", want: "redirects without TLS"},
+		{path: "/help", want: "served without TLS"},
+		{path: "/page", want: "served without TLS"},
+		{path: "/empty", want: "served without TLS"},
+		{path: "/missing", want: "refused (status 404)"},
+		{path: "/broken", want: "refused (status 503)"},
+	}
+	for _, test := range tests {
+		if got := plainHTTPBehaviour(probeRequest("plain http", server.URL+test.path)); got != test.want {
+			t.Errorf("%v: got %q, want %q", test.path, got, test.want)
+		}
+	}
+
+	server.Close()
+	if got := plainHTTPBehaviour(probeRequest("plain http", server.URL+"/help")); got != "not served" {
+		t.Errorf("closed server: got %q, want %q", got, "not served")
+	}
+}
+
+func TestProbe(t *testing.T) {
+	server, requested := rdapServer(t, map[string]string{
+		"/help":               `{"rdapConformance": ["rdap_level_0", "paging"]}`,
+		"/domain/nic.example": `{"objectClassName": "domain", "ldhName": "nic.example"}`,
+		"/domains":            `{"domainSearchResults": []}`,
+	})
+
+	report := Probe("example", server.URL+"/", "domain/nic.example")
+	if report.HTTPS || report.PlainHTTP != "" {
+		t.Errorf("plain HTTP checked for an http:// server: %+v", report)
+	}
+	if !reflect.DeepEqual(report.Conformance, []string{"rdap_level_0", "paging"}) || !reflect.DeepEqual(report.Capabilities, Capabilities(report.Conformance)) {
+		t.Errorf("conformance %q, capabilities %q", report.Conformance, report.Capabilities)
+	}
+
+	supported := map[string]bool{}
+	for _, check := range report.Checks {
+		supported[check.Name] = check.Supported
+	}
+	want := map[string]bool{"help": true, "lookup": true, "domain search": true}
+	for _, search := range probeSearches[1:] {
+		want[search[0]] = false
+	}
+	if !reflect.DeepEqual(supported, want) || len(report.Checks) != 2+len(probeSearches) {
+		t.Errorf("checks %v, want %v", supported, want)
+	}
+	if got := requested(); got[0] != "/help" || got[1] != "/domain/nic.example" {
+		t.Errorf("requested %q", got)
+	}
+}
+
+// An HTTPS server is also asked over plain HTTP on the same port, which a
+// TLS listener refuses
+func TestProbeHTTPS(t *testing.T) {
+	quietStatus(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "https://example.org")
+		io.WriteString(w, `{"rdapConformance": ["rdap_level_0"]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	transport := probeClient.Transport
+	probeClient.Transport = server.Client().Transport
+	t.Cleanup(func() { probeClient.Transport = transport })
+
+	report := Probe(server.URL, server.URL+"/", "")
+	if !report.HTTPS || report.PlainHTTP != "refused (status 400)" || report.CORS != "https://example.org" {
+		t.Errorf("got %+v", report)
+	}
+	if plain := report.Checks[1]; plain.Name != "plain http" || !strings.HasPrefix(plain.URL, "http://127.0.0.1:") {
+		t.Errorf("plain check %+v", plain)
+	}
+}
+
+func TestProbeBaseURL(t *testing.T) {
+	server, _ := rdapServer(t, map[string]string{
+		"/dns.json":  `{"services": [[["example", "test"], ["https://rdap.example/"]]]}`,
+		"/asn.json":  `{"services": [[["64496-64511"], ["https://rdap.asn.example/"]], [["65551"], ["https://rdap.single.example/"]]]}`,
+		"/ipv4.json": `{"services": [[["192.0.0.0/8"], ["https://rdap.ipv4.example/"]]]}`,
+		"/ipv6.json": `{"services": [[["2001:db8::/32"], ["https://rdap.ipv6.example/"]], [["2001::/16"], ["https://rdap.wide.example/"]]]}`,
+	})
+	registryBaseURL := server.URL + "/"
+
+	tests := []struct {
+		target string
+		URL    string
+		lookup string
+	}{
+		{target: "https://rdap.example/rdap", URL: "https://rdap.example/rdap/"},
+		{target: "http://rdap.example/rdap/", URL: "http://rdap.example/rdap/"},
+		{target: "AS64500", URL: "https://rdap.asn.example/", lookup: "autnum/64500"},
+		{target: "as65551", URL: "https://rdap.single.example/", lookup: "autnum/65551"},
+		{target: "192.0.2.1", URL: "https://rdap.ipv4.example/", lookup: "ip/192.0.2.1"},
+		{target: "192.0.2.0/24", URL: "https://rdap.ipv4.example/", lookup: "ip/192.0.2.0/24"},
+		{target: "2001:db8::1", URL: "https://rdap.ipv6.example/", lookup: "ip/2001:db8::1"},
+		{target: "2001:db8::/48", URL: "https://rdap.ipv6.example/", lookup: "ip/2001:db8::/48"},
+		{target: "example", URL: "https://rdap.example/", lookup: "domain/nic.example"},
+		{target: ".test.", URL: "https://rdap.example/", lookup: "domain/nic.test"},
+	}
+	for _, test := range tests {
+		URL, lookup, err := ProbeBaseURL(test.target, registryBaseURL)
+		if err != nil || URL != test.URL || lookup != test.lookup {
+			t.Errorf("%v: got %q %q, error %v, want %q %q", test.target, URL, lookup, err, test.URL, test.lookup)
+		}
+	}
+
+	for _, target := range []string{"AS1", "198.51.100.1", "2002::1", "invalid"} {
+		if URL, _, err := ProbeBaseURL(target, registryBaseURL); err == nil {
+			t.Errorf("%v: got %q, want an error", target, URL)
+		}
+	}
+}
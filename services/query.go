@@ -0,0 +1,185 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Lookup result holding the decoded object alongside the exact bytes the
+// RDAP server returned
+type Result struct {
+	Query     string
+	ServerURL string
+	FetchedAt time.Time
+	Raw       json.RawMessage
+
+	// Capabilities of the server recorded when the result was fetched
+	Capabilities []string
+
+	Domain    *m.Domain
+	IPNetwork *m.IPNetwork
+	Autonum   *m.Autonum
+
+	// Only set by link traversal
+	Entity     *m.Entity
+	Nameserver *m.Nameserver
+}
+
+// Decode the raw response of a result by its object class
+func (result *Result) decode(objectClassName string) error {
+	switch objectClassName {
+	case "domain":
+		result.Domain = &m.Domain{}
+		return json.Unmarshal(result.Raw, result.Domain)
+	case "ip network":
+		result.IPNetwork = &m.IPNetwork{}
+		return json.Unmarshal(result.Raw, result.IPNetwork)
+	case "autnum":
+		result.Autonum = &m.Autonum{}
+		return json.Unmarshal(result.Raw, result.Autonum)
+	case "entity":
+		result.Entity = &m.Entity{}
+		return json.Unmarshal(result.Raw, result.Entity)
+	case "nameserver":
+		result.Nameserver = &m.Nameserver{}
+		return json.Unmarshal(result.Raw, result.Nameserver)
+	}
+	return fmt.Errorf("unsupported objectClassName %q", objectClassName)
+}
+
+// Bootstrap registries already fetched this run, keyed by URL
+var (
+	bootstrapCache      = map[string]m.BootstrapRegistry{}
+	bootstrapCacheMutex sync.Mutex
+)
+
+// Query an RDAP server and return the unmodified response body with the
+// capabilities the server has advertised so far
+func queryRDAPServer(RDAPServerURL string) ([]byte, []string, error) {
+	RDAPServerURL = capabilityRequestURL(RDAPServerURL)
+	queryResponse, err := http.Get(RDAPServerURL)
+
+	if err != nil {
+		if os.IsTimeout(err) {
+			return nil, nil, fmt.Errorf("timeout querying remote RDAP server %v. Please try again", RDAPServerURL)
+		}
+		return nil, nil, fmt.Errorf("error querying RDAP service URL:\n%v", err)
+	}
+
+	queryResponseBody, err := io.ReadAll(queryResponse.Body)
+	queryResponse.Body.Close()
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading query response:\n%v", err)
+	} else if queryResponse.StatusCode == 429 {
+		// Querying too much 429 returned from IANA
+		return nil, nil, fmt.Errorf("returned 429...Slow down there cowboy on the requests you are being throttled. Go take a lap around the neighboorhood before your next query")
+	} else if queryResponse.StatusCode != 200 {
+		return nil, nil, fmt.Errorf("did not recieve \"200 OK\" status code from %v: %v", RDAPServerURL, queryResponse.StatusCode)
+	}
+
+	return queryResponseBody, recordCapabilities(RDAPServerURL, queryResponseBody), nil
+}
+
+// Fetch an IANA bootstrap service registry, once per run
+// https://datatracker.ietf.org/doc/html/rfc9224#section-4
+func getBootstrapRegistry(registryURL string) (m.BootstrapRegistry, error) {
+	var bootstrapRegistryData m.BootstrapRegistry
+
+	bootstrapCacheMutex.Lock()
+	defer bootstrapCacheMutex.Unlock()
+
+	if cached, ok := bootstrapCache[registryURL]; ok {
+		return cached, nil
+	}
+
+	queryResponseBody, _, err := queryRDAPServer(registryURL)
+	if err != nil {
+		return bootstrapRegistryData, fmt.Errorf("IANA RDAP service registry: %v", err)
+	}
+
+	err = json.Unmarshal(queryResponseBody, &bootstrapRegistryData)
+	if err != nil {
+		return bootstrapRegistryData, fmt.Errorf("error un-marshalling IANA RDAP service registry:\n%v", err)
+	}
+
+	bootstrapCache[registryURL] = bootstrapRegistryData
+	return bootstrapRegistryData, nil
+}
+
+// Look up a domain, IP address or ASN ("AS" prefixed), detecting which from
+// the indicator
+func LookupIndicator(indicator string, registryBaseURL string) ([]Result, error) {
+	if strings.HasPrefix(strings.ToUpper(indicator), "AS") {
+		if _, err := parseASN(indicator); err == nil {
+			result, err := LookupAutonum(indicator, registryBaseURL+"asn.json")
+			return []Result{result}, err
+		}
+	}
+	if address, err := netip.ParseAddr(indicator); err == nil {
+		result, err := LookupIP(address, registryBaseURL)
+		return []Result{result}, err
+	}
+	return LookupDomain(indicator, registryBaseURL+"dns.json")
+}
+
+// Look up an IPv4 or IPv6 address
+func LookupIP(address netip.Addr, registryBaseURL string) (Result, error) {
+	if address.Is4() || address.Is4In6() {
+		return LookupIPv4(address.Unmap().String(), registryBaseURL+"ipv4.json")
+	}
+	return LookupIPv6(address.String(), registryBaseURL+"ipv6.json")
+}
+
+// Read indicators from a file, one per line. Blank lines and lines starting
+// with "#" are skipped.
+func ReadIndicators(inputLocation string) ([]string, error) {
+	var indicators []string
+
+	inputFile, err := os.ReadFile(inputLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(inputFile), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		indicators = append(indicators, line)
+	}
+	return indicators, nil
+}
+
+// Query every indicator and write all results together
+func GetBulkData(indicators []string, registryBaseURL string, options Options) {
+	results := LookupIndicators(indicators, registryBaseURL)
+	WriteResults(results, options)
+
+	fmt.Fprintf(Status, "\n\n($) Query Completed\n\n")
+}
+
+// Query every indicator. Failed lookups are reported and skipped.
+func LookupIndicators(indicators []string, registryBaseURL string) []Result {
+	var results []Result
+
+	for _, indicator := range indicators {
+		fmt.Fprintf(Status, "\n(+) Querying RDAP Service for:\t%v", indicator)
+		indicatorResults, err := LookupIndicator(indicator, registryBaseURL)
+		if err != nil {
+			fmt.Fprintf(Status, "\n(!) Lookup failed for %v: %v", indicator, err)
+			continue
+		}
+		results = append(results, indicatorResults...)
+	}
+	return results
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	m "github.com/kadonnelly13/rdapq/models"
+	"go.yaml.in/yaml/v3"
+)
+
+// Where progress and error messages are written. Set to os.Stderr when results
+// are written to stdout so they can be piped.
+var Status io.Writer = os.Stdout
+
+// Output options shared by every query type
+type Options struct {
+	OutputLocation  string
+	Format          string
+	RawOutput       bool
+	Sink            SinkOptions
+	HistoryLocation string
+	Traverse        TraverseOptions
+	Hierarchy       bool
+	EnrichNS        bool
+	RegistryBaseURL string
+}
+
+// Renderer writes a set of lookup results in one output format
+type Renderer interface {
+	Render(w io.Writer, results []Result) error
+}
+
+// Supported values for the -format flag
+var Formats = []string{"text", "json", "ndjson", "csv", "yaml", "markdown", "stix", "misp", "dot", "graphml", "graphjson", "ds"}
+
+// Return the renderer for an output format. The graph formats also draw the
+// links followed by Traverse when given its graph.
+func NewRenderer(format string, rawOutput bool, traversal *Graph) (Renderer, error) {
+	switch format {
+	case "text":
+		return textRenderer{}, nil
+	case "json":
+		return jsonRenderer{raw: rawOutput}, nil
+	case "ndjson":
+		return ndjsonRenderer{raw: rawOutput}, nil
+	case "csv":
+		return csvRenderer{}, nil
+	case "yaml":
+		return yamlRenderer{}, nil
+	case "markdown":
+		return markdownRenderer{}, nil
+	case "stix":
+		return stixRenderer{}, nil
+	case "misp":
+		return mispRenderer{}, nil
+	case "dot":
+		return dotRenderer{traversal: traversal}, nil
+	case "graphml":
+		return graphmlRenderer{traversal: traversal}, nil
+	case "graphjson":
+		return graphJSONRenderer{traversal: traversal}, nil
+	case "ds":
+		return dsRenderer{}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, choose one of: %v", format, strings.Join(Formats, ", "))
+}
+
+// Print results, write them out according to the output options, save them
+// to the history database and send them to the configured sink. With a
+// traversal depth the objects reached by following links are included.
+func WriteResults(results []Result, options Options) {
+	var traversal *Graph
+	if options.Traverse.Depth > 0 {
+		traversal = Traverse(results, options.Traverse)
+		for _, skipped := range traversal.Skipped {
+			fmt.Fprintf(Status, "\n(!) Request budget reached, not followed:\t%v", skipped)
+		}
+		results = traversal.Results()
+	}
+	if options.Hierarchy {
+		results = NetworkHierarchy(results, options.Traverse.Budget)
+	}
+	if options.EnrichNS {
+		results = append(results, EnrichNameservers(results, options.RegistryBaseURL)...)
+	}
+
+	writeOutput(results, traversal, options)
+
+	if options.HistoryLocation != "" {
+		history, err := OpenHistory(options.HistoryLocation)
+		if err == nil {
+			err = history.Save(results)
+			history.Close()
+		}
+		if err != nil {
+			fmt.Fprintf(Status, "\n(!) %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if options.Sink.Type != "" {
+		if err := SendResults(results, options.Sink); err != nil {
+			fmt.Fprintf(Status, "\n(!) %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// Text is always printed to stdout; with -output the results are also written
+// to the file in the chosen format (JSON for text). Any other format without
+// -output is written to stdout instead of the text view.
+func writeOutput(results []Result, traversal *Graph, options Options) {
+	format := options.Format
+	if format == "" {
+		format = "text"
+	}
+
+	if format == "text" || options.OutputLocation != "" {
+		textRenderer{hierarchy: options.Hierarchy, nameserverHosts: options.EnrichNS}.Render(os.Stdout, results)
+	}
+	if format == "text" {
+		if options.OutputLocation == "" {
+			return
+		}
+		format = "json"
+	}
+
+	renderer, err := NewRenderer(format, options.RawOutput, traversal)
+	if err != nil {
+		fmt.Fprintf(Status, "\n(!) %v\n", err)
+		os.Exit(1)
+	}
+
+	var output bytes.Buffer
+	if err := renderer.Render(&output, results); err != nil {
+		fmt.Fprintf(Status, "\n(!) Error rendering %v output:\n%v\n", format, err)
+		os.Exit(1)
+	}
+
+	if options.OutputLocation == "" {
+		os.Stdout.Write(output.Bytes())
+		return
+	}
+
+	err = os.WriteFile(options.OutputLocation, output.Bytes(), 0644)
+	if err != nil {
+		fmt.Fprintf(Status, "\n(!) Error creating output data file\n%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Return the decoded object of a result
+func (result Result) object() any {
+	if result.Domain != nil {
+		return result.Domain
+	} else if result.IPNetwork != nil {
+		return result.IPNetwork
+	} else if result.Autonum != nil {
+		return result.Autonum
+	} else if result.Entity != nil {
+		return result.Entity
+	} else if result.Nameserver != nil {
+		return result.Nameserver
+	}
+	return nil
+}
+
+// Pretty printed text, ending with the allocation chain of the IP networks
+// when the hierarchy was walked and the nameserver hosting report when
+// nameservers were enriched
+type textRenderer struct {
+	hierarchy       bool
+	nameserverHosts bool
+}
+
+// The first domain result of a query is the registry's, later ones for the
+// same query come from related links and are checked against it.
+func (r textRenderer) Render(w io.Writer, results []Result) error {
+	registries := map[string]Result{}
+	for _, result := range results {
+		if result.Domain != nil {
+			prettyPrintDomainData(w, *result.Domain)
+			if registry, found := registries[result.Query]; found {
+				printConsistency(w, registry, result)
+			} else {
+				registries[result.Query] = result
+			}
+		} else if result.IPNetwork != nil {
+			prettyPrintIPData(w, *result.IPNetwork)
+		} else if result.Autonum != nil {
+			prettyPrintAutonumData(w, *result.Autonum)
+		} else if result.Entity != nil {
+			fmt.Fprintf(w, "\n\nRDAP Entity: %v", result.ServerURL)
+			printEntities(w, []m.Entity{*result.Entity})
+		} else if result.Nameserver != nil {
+			fmt.Fprintf(w, "\n\nRDAP Nameserver: %v", result.ServerURL)
+			fmt.Fprintf(w, "\n\tLDH Name: %v", result.Nameserver.LdhName)
+			fmt.Fprintf(w, "\n\tIPv4: %v", strings.Join(result.Nameserver.IPAddresses.V4, ", "))
+			fmt.Fprintf(w, "\n\tIPv6: %v", strings.Join(result.Nameserver.IPAddresses.V6, ", "))
+		}
+		printCapabilities(w, result.capabilities())
+	}
+	if r.hierarchy {
+		printAllocationChain(w, results)
+	}
+	if r.nameserverHosts {
+		printNameserverHosts(w, NameserverHosts(results))
+	}
+	return nil
+}
+
+// A single JSON document. One result is written as-is, several as an array.
+type jsonRenderer struct {
+	raw bool
+}
+
+func (r jsonRenderer) Render(w io.Writer, results []Result) error {
+	var output []byte
+	var err error
+
+	if r.raw {
+		if len(results) == 1 {
+			output = results[0].Raw
+		} else {
+			output = append(output, "[\n"...)
+			for i, result := range results {
+				if i > 0 {
+					output = append(output, ",\n"...)
+				}
+				output = append(output, result.Raw...)
+			}
+			output = append(output, "\n]"...)
+		}
+	} else {
+		var objects []any
+		for _, result := range results {
+			objects = append(objects, result.object())
+		}
+		if len(objects) == 1 {
+			output, err = json.MarshalIndent(objects[0], "", "\t")
+		} else {
+			output, err = json.MarshalIndent(objects, "", "\t")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = w.Write(append(output, '\n'))
+	return err
+}
+
+// Newline delimited JSON, one result per line
+type ndjsonRenderer struct {
+	raw bool
+}
+
+func (r ndjsonRenderer) Render(w io.Writer, results []Result) error {
+	for _, result := range results {
+		var line bytes.Buffer
+		if r.raw {
+			if err := json.Compact(&line, result.Raw); err != nil {
+				return err
+			}
+		} else {
+			object, err := json.Marshal(result.object())
+			if err != nil {
+				return err
+			}
+			line.Write(object)
+		}
+		line.WriteByte('\n')
+		if _, err := w.Write(line.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Comma separated summary records with a header row
+type csvRenderer struct{}
+
+func (csvRenderer) Render(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	writer.Write(SummaryFields)
+	for _, result := range results {
+		writer.Write(Summarize(result).Row())
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Markdown table of summary records
+type markdownRenderer struct{}
+
+// A cell ends at "|" and a row at a line break, so both are escaped
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\r", "<br>", "\n", "<br>")
+
+func (markdownRenderer) Render(w io.Writer, results []Result) error {
+	fmt.Fprintf(w, "| %v |\n", strings.Join(SummaryFields, " | "))
+	fmt.Fprintf(w, "|%v\n", strings.Repeat(" --- |", len(SummaryFields)))
+	for _, result := range results {
+		row := Summarize(result).Row()
+		for i, cell := range row {
+			row[i] = markdownEscaper.Replace(cell)
+		}
+		fmt.Fprintf(w, "| %v |\n", strings.Join(row, " | "))
+	}
+	return nil
+}
+
+// YAML documents, one per result, keeping the RDAP member order
+type yamlRenderer struct{}
+
+func (yamlRenderer) Render(w io.Writer, results []Result) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	for _, result := range results {
+		object, err := json.Marshal(result.object())
+		if err != nil {
+			return err
+		}
+
+		// JSON is valid YAML, decoding into a node keeps the member order
+		var document yaml.Node
+		if err := yaml.Unmarshal(object, &document); err != nil {
+			return err
+		}
+		resetYAMLStyle(&document)
+
+		if err := encoder.Encode(&document); err != nil {
+			return err
+		}
+	}
+	return encoder.Close()
+}
+
+// Clear the flow style carried over from the JSON source so the encoder
+// writes block style YAML
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
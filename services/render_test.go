@@ -0,0 +1,159 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Decode a response into a result the way a lookup does
+func decodedResult(t *testing.T, query string, objectClassName string, raw string) Result {
+	t.Helper()
+	result := Result{Query: query, ServerURL: "https://rdap.example/" + query, Raw: json.RawMessage(raw)}
+	if err := result.decode(objectClassName); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+const renderDomain = `{
+	"objectClassName": "domain",
+	"ldhName": "example.com",
+	"status": ["active"],
+	"entities": [{
+		"objectClassName": "entity",
+		"roles": ["registrar"],
+		"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example\nRegistrar | Inc"]]]
+	}]
+}`
+
+const renderNetwork = `{"objectClassName": "ip network", "handle": "NET-192-0-2-0-1", "startAddress": "192.0.2.0", "endAddress": "192.0.2.255"}`
+
+func TestRenderers(t *testing.T) {
+	domain := decodedResult(t, "example.com", "domain", renderDomain)
+	network := decodedResult(t, "192.0.2.1", "ip network", renderNetwork)
+
+	tests := []struct {
+		name    string
+		format  string
+		raw     bool
+		results []Result
+		check   func(t *testing.T, output string)
+	}{
+		{
+			name:    "csv header and one row per result",
+			format:  "csv",
+			results: []Result{domain, network},
+			check: func(t *testing.T, output string) {
+				rows, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(SummaryFields, ",") {
+					t.Errorf("unexpected rows: %q", rows)
+				}
+				if rows[1][1] != "example.com" || rows[2][1] != "192.0.2.1" {
+					t.Errorf("unexpected queries: %q, %q", rows[1][1], rows[2][1])
+				}
+			},
+		},
+		{
+			name:    "markdown escapes pipes and line breaks",
+			format:  "markdown",
+			results: []Result{domain},
+			check: func(t *testing.T, output string) {
+				lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+				if len(lines) != 3 {
+					t.Fatalf("%v lines, want 3:\n%v", len(lines), output)
+				}
+				if !strings.Contains(lines[2], `| Example<br>Registrar \| Inc |`) {
+					t.Errorf("registrar cell not escaped: %v", lines[2])
+				}
+			},
+		},
+		{
+			name:    "ndjson writes one line per result",
+			format:  "ndjson",
+			results: []Result{domain, network},
+			check: func(t *testing.T, output string) {
+				lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+				if len(lines) != 2 {
+					t.Fatalf("%v lines, want 2:\n%v", len(lines), output)
+				}
+				for _, line := range lines {
+					if !json.Valid([]byte(line)) {
+						t.Errorf("invalid JSON line: %v", line)
+					}
+				}
+			},
+		},
+		{
+			name:    "raw ndjson compacts the response",
+			format:  "ndjson",
+			raw:     true,
+			results: []Result{domain},
+			check: func(t *testing.T, output string) {
+				var want bytes.Buffer
+				json.Compact(&want, []byte(renderDomain))
+				if output != want.String()+"\n" {
+					t.Errorf("got %v, want %v", output, want.String())
+				}
+			},
+		},
+		{
+			name:    "json writes one result as an object",
+			format:  "json",
+			results: []Result{domain},
+			check: func(t *testing.T, output string) {
+				var object map[string]any
+				if err := json.Unmarshal([]byte(output), &object); err != nil || object["ldhName"] != "example.com" {
+					t.Errorf("not the domain object (%v): %v", err, output)
+				}
+			},
+		},
+		{
+			name:    "json writes several results as an array",
+			format:  "json",
+			results: []Result{domain, network},
+			check: func(t *testing.T, output string) {
+				var objects []map[string]any
+				if err := json.Unmarshal([]byte(output), &objects); err != nil || len(objects) != 2 {
+					t.Errorf("not an array of 2 objects (%v): %v", err, output)
+				}
+			},
+		},
+		{
+			name:    "yaml is written in block style",
+			format:  "yaml",
+			results: []Result{network},
+			check: func(t *testing.T, output string) {
+				want := "objectClassName: ip network\nhandle: NET-192-0-2-0-1\nstartAddress: 192.0.2.0\nendAddress: 192.0.2.255\n"
+				if output != want {
+					t.Errorf("got:\n%v\nwant:\n%v", output, want)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			renderer, err := NewRenderer(test.format, test.raw, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var output bytes.Buffer
+			if err := renderer.Render(&output, test.results); err != nil {
+				t.Fatal(err)
+			}
+			test.check(t, output.String())
+		})
+	}
+}
+
+func TestNewRendererUnknownFormat(t *testing.T) {
+	if _, err := NewRenderer("xml", false, nil); err == nil || !strings.Contains(err.Error(), `unknown output format "xml"`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
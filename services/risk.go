@@ -0,0 +1,202 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Triage facts and a risk score for domains. Newly registered domains on a
+// short registration behind a privacy service are the usual phishing profile.
+
+// Points added for each risk factor and the day thresholds that trigger them
+type RiskWeights struct {
+	NewDomainDays    int `json:"newDomainDays"`
+	NewDomain        int `json:"newDomain"`
+	YoungDomainDays  int `json:"youngDomainDays"`
+	YoungDomain      int `json:"youngDomain"`
+	RecentChangeDays int `json:"recentChangeDays"`
+	RecentChange     int `json:"recentChange"`
+	ShortPeriodDays  int `json:"shortPeriodDays"`
+	ShortPeriod      int `json:"shortPeriod"`
+	ExpiringDays     int `json:"expiringDays"`
+	Expiring         int `json:"expiring"`
+	Hold             int `json:"hold"`
+	PendingDelete    int `json:"pendingDelete"`
+	PrivacyProxy     int `json:"privacyProxy"`
+	NoDNSSEC         int `json:"noDnssec"`
+}
+
+// Default weights, the score is capped at 100
+var DefaultRiskWeights = RiskWeights{
+	NewDomainDays:    30,
+	NewDomain:        40,
+	YoungDomainDays:  180,
+	YoungDomain:      20,
+	RecentChangeDays: 7,
+	RecentChange:     10,
+	ShortPeriodDays:  366,
+	ShortPeriod:      10,
+	ExpiringDays:     30,
+	Expiring:         10,
+	Hold:             30,
+	PendingDelete:    30,
+	PrivacyProxy:     15,
+	NoDNSSEC:         5,
+}
+
+// Weights used when scoring, replaced by the -risk-config file
+var RiskConfig = DefaultRiskWeights
+
+// Facts derived from a domain's events, statuses and contacts. Durations are
+// in whole days and nil when the event is missing.
+type DomainRisk struct {
+	AgeDays                *int     `json:"ageDays,omitempty"`
+	DaysSinceChange        *int     `json:"daysSinceChange,omitempty"`
+	DaysToExpiry           *int     `json:"daysToExpiry,omitempty"`
+	RegistrationPeriodDays *int     `json:"registrationPeriodDays,omitempty"`
+	HoldStatuses           []string `json:"holdStatuses,omitempty"`
+	PrivacyProxy           bool     `json:"privacyProxy"`
+	DNSSEC                 bool     `json:"dnssec"`
+	Score                  int      `json:"score"`
+	Reasons                []string `json:"reasons,omitempty"`
+}
+
+// Registrant names used by privacy and proxy services. "REDACTED FOR
+// PRIVACY" is registry redaction, not a proxy, and is not matched.
+var privacyProxyMarkers = []string{
+	"privacy", "proxy", "whoisguard", "withheld", "private by design", "data protected", "identity protect",
+}
+
+// Read risk weights from a JSON file, members missing from the file keep
+// their default
+func LoadRiskWeights(location string) (RiskWeights, error) {
+	weights := DefaultRiskWeights
+	data, err := os.ReadFile(location)
+	if err != nil {
+		return weights, err
+	}
+	if err := json.Unmarshal(data, &weights); err != nil {
+		return weights, fmt.Errorf("%v: %v", location, err)
+	}
+	return weights, nil
+}
+
+// Derive the triage facts of a domain and score them
+func ScoreDomain(domain m.Domain, now time.Time, weights RiskWeights) DomainRisk {
+	var risk DomainRisk
+
+	registered, hasRegistration := eventTime(domain.Events, "registration")
+	changed, hasChange := eventTime(domain.Events, "last changed")
+	expires, hasExpiration := eventTime(domain.Events, "expiration")
+	if hasRegistration {
+		risk.AgeDays = daysBetween(registered, now)
+	}
+	if hasChange {
+		risk.DaysSinceChange = daysBetween(changed, now)
+	}
+	if hasExpiration {
+		risk.DaysToExpiry = daysBetween(now, expires)
+	}
+	if hasRegistration && hasExpiration {
+		risk.RegistrationPeriodDays = daysBetween(registered, expires)
+	}
+
+	for _, status := range domain.Status {
+		switch normalizeStatus(status) {
+		case "serverhold", "clienthold", "pendingdelete":
+			risk.HoldStatuses = append(risk.HoldStatuses, status)
+		}
+	}
+	risk.PrivacyProxy = isPrivacyProxy(domain.Entities)
+	risk.DNSSEC = domain.SecureDNS.DelegationSigned != nil && *domain.SecureDNS.DelegationSigned
+
+	add := func(points int, reason string) {
+		if points != 0 {
+			risk.Score += points
+			risk.Reasons = append(risk.Reasons, fmt.Sprintf("%v (+%v)", reason, points))
+		}
+	}
+	if age := risk.AgeDays; age != nil {
+		if *age <= weights.NewDomainDays {
+			add(weights.NewDomain, fmt.Sprintf("registered %v days ago", *age))
+		} else if *age <= weights.YoungDomainDays {
+			add(weights.YoungDomain, fmt.Sprintf("registered %v days ago", *age))
+		}
+	}
+	if since := risk.DaysSinceChange; since != nil && *since <= weights.RecentChangeDays {
+		add(weights.RecentChange, fmt.Sprintf("changed %v days ago", *since))
+	}
+	if period := risk.RegistrationPeriodDays; period != nil && *period <= weights.ShortPeriodDays {
+		add(weights.ShortPeriod, fmt.Sprintf("registered for %v days", *period))
+	}
+	if left := risk.DaysToExpiry; left != nil && *left < 0 {
+		add(weights.Expiring, fmt.Sprintf("expired %v days ago", -*left))
+	} else if left != nil && *left <= weights.ExpiringDays {
+		add(weights.Expiring, fmt.Sprintf("expires in %v days", *left))
+	}
+	for _, status := range risk.HoldStatuses {
+		if normalizeStatus(status) == "pendingdelete" {
+			add(weights.PendingDelete, "status "+status)
+		} else {
+			add(weights.Hold, "status "+status)
+		}
+	}
+	if risk.PrivacyProxy {
+		add(weights.PrivacyProxy, "privacy or proxy registrant")
+	}
+	if !risk.DNSSEC {
+		add(weights.NoDNSSEC, "delegation not signed")
+	}
+	risk.Score = min(risk.Score, 100)
+
+	return risk
+}
+
+// Parse the date of the first event with the given action whose date parses
+func eventTime(events []m.Events, action string) (time.Time, bool) {
+	for _, event := range events {
+		if event.EventAction != action {
+			continue
+		}
+		if date, err := event.Date(); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func daysBetween(from time.Time, to time.Time) *int {
+	days := int(math.Floor(to.Sub(from).Hours() / 24))
+	return &days
+}
+
+// Reduce RDAP ("client hold") and EPP ("clientHold") status spellings to one form
+func normalizeStatus(status string) string {
+	return strings.ToLower(strings.ReplaceAll(status, " ", ""))
+}
+
+// Report whether the registrant is a privacy or proxy service
+func isPrivacyProxy(entities []m.Entity) bool {
+	registrant := findEntity(entities, "registrant")
+	if registrant == nil {
+		return false
+	}
+	if slices.Contains(registrant.Roles, "proxy") {
+		return true
+	}
+	name := strings.ToLower(vcardText(*registrant, "fn") + " " + vcardText(*registrant, "org"))
+	name = strings.ReplaceAll(name, "redacted for privacy", "")
+	for _, marker := range privacyProxyMarkers {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
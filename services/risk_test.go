@@ -0,0 +1,175 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Decode a domain response for scoring
+func riskDomain(t *testing.T, raw string) m.Domain {
+	t.Helper()
+	var domain m.Domain
+	if err := json.Unmarshal([]byte(raw), &domain); err != nil {
+		t.Fatal(err)
+	}
+	return domain
+}
+
+func days(value int) *int {
+	return &value
+}
+
+func TestScoreDomain(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		raw     string
+		weights RiskWeights
+		want    DomainRisk
+	}{
+		{
+			name: "new registration on a one year term",
+			raw: `{"events": [
+				{"eventAction": "registration", "eventDate": "2026-02-20T12:00:00Z"},
+				{"eventAction": "last changed", "eventDate": "2026-02-27T18:00:00Z"},
+				{"eventAction": "expiration", "eventDate": "2027-02-20T12:00:00Z"}
+			], "secureDNS": {"delegationSigned": true}}`,
+			weights: DefaultRiskWeights,
+			want: DomainRisk{
+				AgeDays: days(9), DaysSinceChange: days(1), DaysToExpiry: days(356), RegistrationPeriodDays: days(365),
+				DNSSEC: true, Score: 60,
+				Reasons: []string{"registered 9 days ago (+40)", "changed 1 days ago (+10)", "registered for 365 days (+10)"},
+			},
+		},
+		{
+			name: "old registration expiring soon",
+			raw: `{"events": [
+				{"eventAction": "registration", "eventDate": "2016-03-10T00:00:00Z"},
+				{"eventAction": "expiration", "eventDate": "2026-03-10T00:00:00Z"}
+			], "secureDNS": {"delegationSigned": false}}`,
+			weights: DefaultRiskWeights,
+			want: DomainRisk{
+				AgeDays: days(3643), DaysToExpiry: days(8), RegistrationPeriodDays: days(3652), Score: 15,
+				Reasons: []string{"expires in 8 days (+10)", "delegation not signed (+5)"},
+			},
+		},
+		{
+			name:    "expired",
+			raw:     `{"events": [{"eventAction": "expiration", "eventDate": "2026-02-25T12:00:00Z"}], "secureDNS": {"delegationSigned": true}}`,
+			weights: DefaultRiskWeights,
+			want:    DomainRisk{DaysToExpiry: days(-4), DNSSEC: true, Score: 10, Reasons: []string{"expired 4 days ago (+10)"}},
+		},
+		{
+			name: "privacy proxy registrant",
+			raw: `{"entities": [{"roles": ["registrant"], "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Domains By Proxy, LLC"]]]}],
+				"secureDNS": {"delegationSigned": true}}`,
+			weights: DefaultRiskWeights,
+			want:    DomainRisk{PrivacyProxy: true, DNSSEC: true, Score: 15, Reasons: []string{"privacy or proxy registrant (+15)"}},
+		},
+		{
+			name: "registry redaction is not a proxy",
+			raw: `{"entities": [{"roles": ["registrant"], "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "REDACTED FOR PRIVACY"]]]}],
+				"secureDNS": {"delegationSigned": true}}`,
+			weights: DefaultRiskWeights,
+			want:    DomainRisk{DNSSEC: true},
+		},
+		{
+			name:    "hold statuses in RDAP and EPP spelling",
+			raw:     `{"status": ["active", "client hold", "serverHold", "pending delete"], "secureDNS": {"delegationSigned": true}}`,
+			weights: DefaultRiskWeights,
+			want: DomainRisk{
+				HoldStatuses: []string{"client hold", "serverHold", "pending delete"}, DNSSEC: true, Score: 90,
+				Reasons: []string{"status client hold (+30)", "status serverHold (+30)", "status pending delete (+30)"},
+			},
+		},
+		{
+			name: "score capped at 100",
+			raw: `{"status": ["clientHold", "serverHold", "pendingDelete"], "events": [
+				{"eventAction": "registration", "eventDate": "2026-02-28T12:00:00Z"},
+				{"eventAction": "expiration", "eventDate": "2026-03-28T12:00:00Z"}
+			]}`,
+			weights: DefaultRiskWeights,
+			want: DomainRisk{
+				AgeDays: days(1), DaysToExpiry: days(27), RegistrationPeriodDays: days(28),
+				HoldStatuses: []string{"clientHold", "serverHold", "pendingDelete"}, Score: 100,
+				Reasons: []string{
+					"registered 1 days ago (+40)", "registered for 28 days (+10)", "expires in 27 days (+10)",
+					"status clientHold (+30)", "status serverHold (+30)", "status pendingDelete (+30)", "delegation not signed (+5)",
+				},
+			},
+		},
+		{
+			name:    "missing events leave durations nil",
+			raw:     `{"events": [{"eventAction": "last update of RDAP database", "eventDate": "2026-03-01T00:00:00Z"}, {"eventAction": "registration", "eventDate": "not a date"}]}`,
+			weights: DefaultRiskWeights,
+			want:    DomainRisk{Score: 5, Reasons: []string{"delegation not signed (+5)"}},
+		},
+		{
+			name: "weight overrides",
+			raw: `{"events": [{"eventAction": "registration", "eventDate": "2025-12-01T12:00:00Z"}],
+				"entities": [{"roles": ["registrant", "proxy"]}]}`,
+			weights: func() RiskWeights {
+				weights := DefaultRiskWeights
+				weights.NewDomainDays, weights.NewDomain = 120, 50
+				weights.PrivacyProxy, weights.NoDNSSEC = 0, 25
+				return weights
+			}(),
+			want: DomainRisk{
+				AgeDays: days(90), PrivacyProxy: true, Score: 75,
+				Reasons: []string{"registered 90 days ago (+50)", "delegation not signed (+25)"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if risk := ScoreDomain(riskDomain(t, test.raw), now, test.weights); !reflect.DeepEqual(risk, test.want) {
+				got, _ := json.Marshal(risk)
+				want, _ := json.Marshal(test.want)
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// A -risk-config file overrides the members it sets, the rest keep their default
+func TestLoadRiskWeights(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "risk.json")
+	if err := os.WriteFile(location, []byte(`{"newDomainDays": 60, "noDnssec": 0}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	weights, err := LoadRiskWeights(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultRiskWeights
+	want.NewDomainDays, want.NoDNSSEC = 60, 0
+	if weights != want {
+		t.Errorf("got %+v, want %+v", weights, want)
+	}
+
+	// Summaries score with RiskConfig
+	config := RiskConfig
+	RiskConfig = weights
+	t.Cleanup(func() { RiskConfig = config })
+	registered := time.Now().AddDate(0, 0, -45).UTC().Format(time.RFC3339)
+	result := decodedResult(t, "example.com", "domain", `{"objectClassName": "domain", "ldhName": "example.com", "events": [{"eventAction": "registration", "eventDate": "`+registered+`"}]}`)
+	if summary := Summarize(result); summary.RiskScore == nil || *summary.RiskScore != 40 {
+		t.Errorf("summary risk score %v, want 40", intString(summary.RiskScore))
+	}
+
+	if err := os.WriteFile(location, []byte(`{"newDomain": "forty"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRiskWeights(location); err == nil {
+		t.Error("expected an error for an invalid weight")
+	}
+}
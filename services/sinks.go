@@ -0,0 +1,247 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SIEM sinks that POST the summary record of each result
+//
+// splunk:        Splunk HTTP Event Collector, one event per summary
+//                https://docs.splunk.com/Documentation/Splunk/latest/Data/HECRESTendpoints
+// elasticsearch: Elasticsearch _bulk API, one document per summary
+//                https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-bulk.html
+
+// Supported values for the -sink flag
+var Sinks = []string{"splunk", "elasticsearch"}
+
+// Sink options
+type SinkOptions struct {
+	Type       string
+	URL        string
+	Token      string
+	Index      string
+	SourceType string
+	BatchSize  int
+	Retries    int
+}
+
+// Wait before the first retry, doubled for every retry after it
+var sinkRetryDelay = time.Second
+
+// A SIEM endpoint that stops answering fails the request instead of blocking
+var sinkClient = &http.Client{Timeout: 30 * time.Second}
+
+// Send the summary of every result to the configured sink in batches
+func SendResults(results []Result, options SinkOptions) error {
+	if options.URL == "" {
+		return fmt.Errorf("no URL given for the %v sink", options.Type)
+	}
+	batchSize := options.BatchSize
+	if batchSize < 1 {
+		batchSize = 100
+	}
+
+	sent := 0
+	for start := 0; start < len(results); start += batchSize {
+		batch := results[start:min(start+batchSize, len(results))]
+
+		var delivered int
+		var err error
+		switch options.Type {
+		case "splunk":
+			delivered, err = sendWithRetry(options, splunkRequest, batch)
+		case "elasticsearch":
+			delivered, err = sendWithRetry(options, elasticsearchRequest, batch)
+		default:
+			return fmt.Errorf("unknown sink %q, choose one of: %v", options.Type, strings.Join(Sinks, ", "))
+		}
+		sent += delivered
+		if err != nil {
+			return fmt.Errorf("sent %v of %v results to %v: %v", sent, len(results), options.Type, err)
+		}
+	}
+
+	fmt.Fprintf(Status, "\n(+) Sent %v results to %v", sent, options.Type)
+	return nil
+}
+
+// Build the HTTP request for a batch
+type sinkRequestBuilder func(options SinkOptions, batch []Result) (*http.Request, error)
+
+// POST a batch, retrying on connection errors, 429 and 5xx responses.
+// Documents Elasticsearch rejects with 429 or 5xx in a _bulk response are
+// retried on their own, other rejections are reported once retries end.
+// Returns the number of results delivered.
+func sendWithRetry(options SinkOptions, buildRequest sinkRequestBuilder, batch []Result) (int, error) {
+	var lastErr, rejected error
+	delivered := 0
+	delay := sinkRetryDelay
+
+	for attempt := 0; attempt <= options.Retries; attempt++ {
+		if attempt > 0 {
+			fmt.Fprintf(Status, "\n(!) %v, retrying in %v", lastErr, delay)
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		request, err := buildRequest(options, batch)
+		if err != nil {
+			return delivered, err
+		}
+		response, err := sinkClient.Do(request)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		responseBody, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+
+		if response.StatusCode == 429 || response.StatusCode >= 500 {
+			lastErr = fmt.Errorf("%v returned %v", options.Type, response.Status)
+			continue
+		}
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return delivered, fmt.Errorf("%v returned %v: %s", options.Type, response.Status, bytes.TrimSpace(responseBody))
+		}
+		if options.Type == "elasticsearch" {
+			retry, failed, err := elasticsearchFailures(responseBody, batch)
+			rejected = errors.Join(rejected, err)
+			delivered += len(batch) - len(retry) - failed
+			if len(retry) > 0 {
+				lastErr = fmt.Errorf("elasticsearch rejected %v documents with a retryable status", len(retry))
+				batch = retry
+				continue
+			}
+			return delivered, rejected
+		}
+		return delivered + len(batch), nil
+	}
+
+	return delivered, errors.Join(rejected, lastErr)
+}
+
+// Splunk HEC event envelope
+type splunkEvent struct {
+	Time       float64 `json:"time"`
+	Source     string  `json:"source"`
+	SourceType string  `json:"sourcetype,omitempty"`
+	Index      string  `json:"index,omitempty"`
+	Event      Summary `json:"event"`
+}
+
+func splunkRequest(options SinkOptions, batch []Result) (*http.Request, error) {
+	var body bytes.Buffer
+	for _, result := range batch {
+		summary := Summarize(result)
+		event, err := json.Marshal(splunkEvent{
+			Time:       float64(summary.FetchedAt.UnixMilli()) / 1000,
+			Source:     "rdapq",
+			SourceType: options.SourceType,
+			Index:      options.Index,
+			Event:      summary,
+		})
+		if err != nil {
+			return nil, err
+		}
+		body.Write(event)
+		body.WriteByte('\n')
+	}
+
+	// Accept either the collector base URL or the full event endpoint
+	URL := strings.TrimSuffix(options.URL, "/")
+	if !strings.Contains(URL, "/services/collector") {
+		URL += "/services/collector/event"
+	}
+
+	request, err := http.NewRequest("POST", URL, &body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if options.Token != "" {
+		request.Header.Set("Authorization", "Splunk "+options.Token)
+	}
+	return request, nil
+}
+
+func elasticsearchRequest(options SinkOptions, batch []Result) (*http.Request, error) {
+	index := options.Index
+	if index == "" {
+		index = "rdapq"
+	}
+	action, _ := json.Marshal(map[string]map[string]string{"index": {"_index": index}})
+
+	var body bytes.Buffer
+	for _, result := range batch {
+		document, err := json.Marshal(Summarize(result))
+		if err != nil {
+			return nil, err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(document)
+		body.WriteByte('\n')
+	}
+
+	URL := strings.TrimSuffix(options.URL, "/")
+	if !strings.HasSuffix(URL, "/_bulk") {
+		URL += "/_bulk"
+	}
+
+	request, err := http.NewRequest("POST", URL, &body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	if options.Token != "" {
+		request.Header.Set("Authorization", "ApiKey "+options.Token)
+	}
+	return request, nil
+}
+
+// Return the documents of a successful _bulk response Elasticsearch rejected
+// with a retryable status, and the number and an error describing the rest
+// it rejected. Items are in the order of the batch.
+func elasticsearchFailures(responseBody []byte, batch []Result) ([]Result, int, error) {
+	var response struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(responseBody, &response); err != nil || !response.Errors {
+		return nil, 0, nil
+	}
+
+	var retry []Result
+	failed := 0
+	var reason string
+	for i, item := range response.Items {
+		for _, result := range item {
+			switch {
+			case result.Status == 429 || result.Status >= 500:
+				if i < len(batch) {
+					retry = append(retry, batch[i])
+				}
+			case result.Status > 299:
+				failed++
+				reason = result.Error.Type + ": " + result.Error.Reason
+			}
+		}
+	}
+	if failed == 0 {
+		return retry, 0, nil
+	}
+	return retry, failed, fmt.Errorf("elasticsearch rejected %v documents (%v)", failed, reason)
+}
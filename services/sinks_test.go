@@ -0,0 +1,130 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Results with just enough to summarize
+func sinkResults(queries ...string) []Result {
+	var results []Result
+	for _, query := range queries {
+		results = append(results, Result{Query: query, Raw: json.RawMessage(`{}`)})
+	}
+	return results
+}
+
+// Lines of a newline delimited request body
+func bodyLines(t *testing.T, r *http.Request) []string {
+	t.Helper()
+	var lines []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+func quietSinks(t *testing.T) {
+	status, delay := Status, sinkRetryDelay
+	Status, sinkRetryDelay = io.Discard, 0
+	t.Cleanup(func() { Status, sinkRetryDelay = status, delay })
+}
+
+func TestSplunkSuccess(t *testing.T) {
+	quietSinks(t)
+	var events []splunkEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services/collector/event" || r.Header.Get("Authorization") != "Splunk secret" {
+			t.Errorf("unexpected request %v %v", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		for _, line := range bodyLines(t, r) {
+			var event splunkEvent
+			if err := json.Unmarshal([]byte(line), &event); err != nil {
+				t.Error(err)
+			}
+			events = append(events, event)
+		}
+		w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer server.Close()
+
+	err := SendResults(sinkResults("example.com", "example.net"), SinkOptions{Type: "splunk", URL: server.URL, Token: "secret", Index: "threat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Event.Query != "example.com" || events[1].Index != "threat" || events[0].Source != "rdapq" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
+
+func TestSinkRetriesServerError(t *testing.T) {
+	quietSinks(t)
+	requests, failures := 0, 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if failures > 0 {
+			failures--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer server.Close()
+
+	if err := SendResults(sinkResults("example.com"), SinkOptions{Type: "splunk", URL: server.URL, Retries: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("%v requests, want 2", requests)
+	}
+
+	failures = 2
+	if err := SendResults(sinkResults("example.com"), SinkOptions{Type: "splunk", URL: server.URL, Retries: 1}); err == nil {
+		t.Error("no error once retries are exhausted")
+	}
+}
+
+func TestElasticsearchPartialFailure(t *testing.T) {
+	quietSinks(t)
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+		var queries []string
+		for i, line := range bodyLines(t, r) {
+			if i%2 == 1 {
+				var summary Summary
+				json.Unmarshal([]byte(line), &summary)
+				queries = append(queries, summary.Query)
+			}
+		}
+		batches = append(batches, queries)
+
+		// The first document is indexed, the second throttled and the
+		// third rejected for its mapping
+		if len(batches) == 1 {
+			w.Write([]byte(`{"errors":true,"items":[
+				{"index":{"status":201}},
+				{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}},
+				{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}]}`))
+			return
+		}
+		w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`))
+	}))
+	defer server.Close()
+
+	err := SendResults(sinkResults("a.example", "b.example", "c.example"), SinkOptions{Type: "elasticsearch", URL: server.URL, Retries: 2})
+	if err == nil || !strings.Contains(err.Error(), "sent 2 of 3 results") || !strings.Contains(err.Error(), "rejected 1 documents (mapper_parsing_exception") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(batches) != 2 || len(batches[1]) != 1 || batches[1][0] != "b.example" {
+		t.Errorf("unexpected batches: %v", batches)
+	}
+}
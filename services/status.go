@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"io"
+)
+
+// Meaning of RDAP status values and the EPP status codes they map to
+// https://datatracker.ietf.org/doc/html/rfc8056#section-2
+// https://www.icann.org/resources/pages/epp-status-codes-2014-06-16-en
+
+// EPP equivalent, explanation and category of an RDAP status. Category is
+// "lock", "hold", "pending", "redemption", "grace" or "state". Warning is set
+// for states that take a domain out of DNS or put it at risk of deletion or
+// transfer.
+type StatusInfo struct {
+	RDAP        string
+	EPP         string
+	Category    string
+	Explanation string
+	Warning     string
+}
+
+var statusInfos = []StatusInfo{
+	{"add period", "addPeriod", "grace", "Grace period after registration, the registrar is credited if the domain is deleted.", ""},
+	{"auto renew period", "autoRenewPeriod", "grace", "Grace period after automatic renewal, the registrar is credited if the domain is deleted.", ""},
+	{"renew period", "renewPeriod", "grace", "Grace period after explicit renewal, the registrar is credited if the domain is deleted.", ""},
+	{"transfer period", "transferPeriod", "grace", "Grace period after a transfer, the gaining registrar is credited if the domain is deleted.", ""},
+	{"client delete prohibited", "clientDeleteProhibited", "lock", "The sponsoring registrar blocks deletion of the domain.", ""},
+	{"client renew prohibited", "clientRenewProhibited", "lock", "The sponsoring registrar blocks renewal of the domain.", ""},
+	{"client transfer prohibited", "clientTransferProhibited", "lock", "The sponsoring registrar blocks transfers to another registrar.", ""},
+	{"client update prohibited", "clientUpdateProhibited", "lock", "The sponsoring registrar blocks changes to the domain.", ""},
+	{"server delete prohibited", "serverDeleteProhibited", "lock", "The registry blocks deletion of the domain.", ""},
+	{"server renew prohibited", "serverRenewProhibited", "lock", "The registry blocks renewal of the domain.", ""},
+	{"server transfer prohibited", "serverTransferProhibited", "lock", "The registry blocks transfers to another registrar.", ""},
+	{"server update prohibited", "serverUpdateProhibited", "lock", "The registry blocks changes to the domain.", ""},
+	{"client hold", "clientHold", "hold", "The sponsoring registrar withholds the domain from DNS.", "The domain does not resolve, often for non-payment, abuse or a legal dispute."},
+	{"server hold", "serverHold", "hold", "The registry withholds the domain from DNS.", "The domain does not resolve, often after abuse, a court order or a registry suspension."},
+	{"pending create", "pendingCreate", "pending", "A request to create the domain is being processed.", ""},
+	{"pending delete", "pendingDelete", "pending", "The domain is queued for deletion and cannot be restored.", "The domain will be deleted and released for registration within days."},
+	{"pending renew", "pendingRenew", "pending", "A request to renew the domain is being processed.", ""},
+	{"pending transfer", "pendingTransfer", "pending", "A transfer to another registrar has been requested and awaits approval.", "The domain is moving to another registrar, confirm the transfer was intended."},
+	{"pending update", "pendingUpdate", "pending", "A request to change the domain is being processed.", ""},
+	{"redemption period", "redemptionPeriod", "redemption", "The domain was deleted and can only be restored by the registrar.", "The domain is withheld from DNS and will be deleted unless restored."},
+	{"pending restore", "pendingRestore", "redemption", "The registrar requested restoration of the deleted domain and must submit a restore report.", "The domain returns to the redemption period if the restore is not completed."},
+	{"active", "ok", "state", "Standard status with no pending operations or prohibitions.", ""},
+	{"inactive", "inactive", "state", "No nameservers are delegated, the domain does not resolve.", ""},
+	{"associated", "linked", "state", "The object is referenced by another object, such as a nameserver used by a domain.", ""},
+}
+
+// Look up a status by its RDAP or EPP spelling
+func statusInfo(status string) (StatusInfo, bool) {
+	for _, info := range statusInfos {
+		if normalizeStatus(status) == normalizeStatus(info.RDAP) || normalizeStatus(status) == normalizeStatus(info.EPP) {
+			return info, true
+		}
+	}
+	return StatusInfo{}, false
+}
+
+// Print object statuses with their EPP code and meaning. Statuses with a
+// warning are flagged above the list so they are not missed.
+func printStatuses(w io.Writer, title string, statuses []string) {
+	fmt.Fprintf(w, "\n\n%v", title)
+	for _, status := range statuses {
+		if info, found := statusInfo(status); found && info.Warning != "" {
+			fmt.Fprintf(w, "\n\n(!) %v: %v", status, info.Warning)
+		}
+	}
+	for _, status := range statuses {
+		fmt.Fprintf(w, "\n\n\tStatus:\t\t%v", status)
+		if info, found := statusInfo(status); found {
+			fmt.Fprintf(w, "\n\tEPP Code:\t%v", info.EPP)
+			fmt.Fprintf(w, "\n\tCategory:\t%v", info.Category)
+			fmt.Fprintf(w, "\n\tMeaning:\t%v", info.Explanation)
+		}
+	}
+}
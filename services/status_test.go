@@ -0,0 +1,85 @@
+package services
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStatusInfo(t *testing.T) {
+	tests := []struct {
+		status   string
+		epp      string
+		category string
+		warning  bool
+	}{
+		{status: "client hold", epp: "clientHold", category: "hold", warning: true},
+		{status: "clientHold", epp: "clientHold", category: "hold", warning: true},
+		{status: "CLIENTHOLD", epp: "clientHold", category: "hold", warning: true},
+		{status: "Server Hold", epp: "serverHold", category: "hold", warning: true},
+		{status: "pending delete", epp: "pendingDelete", category: "pending", warning: true},
+		{status: "pendingTransfer", epp: "pendingTransfer", category: "pending", warning: true},
+		{status: "pending update", epp: "pendingUpdate", category: "pending"},
+		{status: "redemptionPeriod", epp: "redemptionPeriod", category: "redemption", warning: true},
+		{status: "client transfer prohibited", epp: "clientTransferProhibited", category: "lock"},
+		{status: "serverUpdateProhibited", epp: "serverUpdateProhibited", category: "lock"},
+		{status: "auto renew period", epp: "autoRenewPeriod", category: "grace"},
+		{status: "renewPeriod", epp: "renewPeriod", category: "grace"},
+		{status: "active", epp: "ok", category: "state"},
+		{status: "OK", epp: "ok", category: "state"},
+		{status: "associated", epp: "linked", category: "state"},
+	}
+
+	for _, test := range tests {
+		info, found := statusInfo(test.status)
+		if !found {
+			t.Errorf("%q not found", test.status)
+			continue
+		}
+		if info.EPP != test.epp || info.Category != test.category || (info.Warning != "") != test.warning {
+			t.Errorf("%q: got %v %v warning %q, want %v %v warning %v", test.status, info.EPP, info.Category, info.Warning, test.epp, test.category, test.warning)
+		}
+	}
+
+	for _, status := range []string{"", "removed", "client hold prohibited"} {
+		if info, found := statusInfo(status); found {
+			t.Errorf("%q matched %+v", status, info)
+		}
+	}
+}
+
+// Every status is reachable by both spellings and has a known category
+func TestStatusInfos(t *testing.T) {
+	categories := []string{"lock", "hold", "pending", "redemption", "grace", "state"}
+	for _, info := range statusInfos {
+		for _, spelling := range []string{info.RDAP, info.EPP} {
+			if found, _ := statusInfo(spelling); found.RDAP != info.RDAP {
+				t.Errorf("%q maps to %q, want %q", spelling, found.RDAP, info.RDAP)
+			}
+		}
+		if !slices.Contains(categories, info.Category) || info.Explanation == "" {
+			t.Errorf("%q has category %q and explanation %q", info.RDAP, info.Category, info.Explanation)
+		}
+	}
+}
+
+// Risky statuses are flagged above the list, unknown statuses are listed
+// without a meaning
+func TestPrintStatuses(t *testing.T) {
+	var output bytes.Buffer
+	printStatuses(&output, "Domain Status", []string{"client transfer prohibited", "clientHold", "custom status"})
+
+	text := output.String()
+	warning := strings.Index(text, "(!) clientHold: The domain does not resolve")
+	first := strings.Index(text, "Status:\t\tclient transfer prohibited")
+	if warning < 0 || first < 0 || warning > first {
+		t.Errorf("hold warning not printed before the statuses:\n%v", text)
+	}
+	if strings.Count(text, "(!)") != 1 {
+		t.Errorf("expected one warning:\n%v", text)
+	}
+	if !strings.Contains(text, "Status:\t\tcustom status") || strings.Count(text, "EPP Code:") != 2 {
+		t.Errorf("unexpected status list:\n%v", text)
+	}
+}
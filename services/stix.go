@@ -0,0 +1,341 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// STIX 2.1 bundle export
+// https://docs.oasis-open.org/cti/stix/v2.1/os/stix-v2.1-os.html
+//
+// Domains map to domain-name, addresses to ipv4-addr/ipv6-addr and origin
+// ASNs to autonomous-system cyber observables. Registrars, registrants and
+// other contacts become identity objects linked by relationships. RDAP
+// details are carried in x_rdap_* custom properties.
+
+// Namespace for deterministic STIX Cyber-observable Object identifiers
+// https://docs.oasis-open.org/cti/stix/v2.1/os/stix-v2.1-os.html#_64yvzeku5a5c
+var stixNamespace = [16]byte{0x00, 0xab, 0xed, 0xb4, 0xaa, 0x42, 0x46, 0x6c, 0x9c, 0x01, 0xfe, 0xd2, 0x33, 0x15, 0xa9, 0xb7}
+
+// STIX timestamp format, UTC with millisecond precision
+const stixTimeFormat = "2006-01-02T15:04:05.000Z"
+
+type stixEvent struct {
+	Action string `json:"action"`
+	Date   string `json:"date"`
+	Actor  string `json:"actor,omitempty"`
+}
+
+// STIX object, covering the properties rdapq uses across object types
+type stixObject struct {
+	Type                 string      `json:"type"`
+	SpecVersion          string      `json:"spec_version"`
+	ID                   string      `json:"id"`
+	Created              string      `json:"created,omitempty"`
+	Modified             string      `json:"modified,omitempty"`
+	Value                string      `json:"value,omitempty"`
+	Number               *uint32     `json:"number,omitempty"`
+	Name                 string      `json:"name,omitempty"`
+	IdentityClass        string      `json:"identity_class,omitempty"`
+	Roles                []string    `json:"roles,omitempty"`
+	ContactInformation   string      `json:"contact_information,omitempty"`
+	RelationshipType     string      `json:"relationship_type,omitempty"`
+	Description          string      `json:"description,omitempty"`
+	SourceRef            string      `json:"source_ref,omitempty"`
+	TargetRef            string      `json:"target_ref,omitempty"`
+	BelongsToRefs        []string    `json:"belongs_to_refs,omitempty"`
+	RdapHandle           string      `json:"x_rdap_handle,omitempty"`
+	RdapName             string      `json:"x_rdap_name,omitempty"`
+	RdapRange            string      `json:"x_rdap_range,omitempty"`
+	RdapStatus           []string    `json:"x_rdap_status,omitempty"`
+	RdapEvents           []stixEvent `json:"x_rdap_events,omitempty"`
+	RdapSource           string      `json:"x_rdap_source,omitempty"`
+	RdapDelegationSigned *bool       `json:"x_rdap_delegation_signed,omitempty"`
+	RdapqRiskScore       *int        `json:"x_rdapq_risk_score,omitempty"`
+}
+
+type stixBundle struct {
+	Type    string        `json:"type"`
+	ID      string        `json:"id"`
+	Objects []*stixObject `json:"objects"`
+}
+
+// Collects objects for a bundle, merging objects shared between results
+type stixBuilder struct {
+	objects []*stixObject
+	index   map[string]*stixObject
+	created string
+}
+
+// STIX 2.1 bundle of every result
+type stixRenderer struct{}
+
+// The same results give the same bundle: objects are sorted by ID, the
+// bundle ID is derived from them and objects are timestamped with the time of
+// the latest lookup
+func (stixRenderer) Render(w io.Writer, results []Result) error {
+	var created time.Time
+	for _, result := range results {
+		if result.FetchedAt.After(created) {
+			created = result.FetchedAt
+		}
+	}
+	if created.IsZero() {
+		created = time.Now()
+	}
+	builder := stixBuilder{index: map[string]*stixObject{}, created: created.UTC().Format(stixTimeFormat)}
+
+	for _, result := range results {
+		if result.Domain != nil {
+			builder.addDomain(result)
+		} else if result.IPNetwork != nil {
+			builder.addIPNetwork(result)
+		} else if result.Autonum != nil {
+			builder.addAutonum(result)
+		}
+	}
+
+	slices.SortFunc(builder.objects, func(a *stixObject, b *stixObject) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	var IDs []string
+	for _, object := range builder.objects {
+		IDs = append(IDs, object.ID)
+	}
+	bundleKey, _ := json.Marshal(IDs)
+
+	bundle := stixBundle{Type: "bundle", ID: "bundle--" + uuid5(stixNamespace, bundleKey), Objects: builder.objects}
+	if bundle.Objects == nil {
+		bundle.Objects = []*stixObject{}
+	}
+
+	output, err := json.MarshalIndent(bundle, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(output, '\n'))
+	return err
+}
+
+// Add an object unless one with the same identifier exists, returning the
+// object held by the bundle
+func (b *stixBuilder) add(object *stixObject) *stixObject {
+	if existing, ok := b.index[object.ID]; ok {
+		return existing
+	}
+	b.index[object.ID] = object
+	b.objects = append(b.objects, object)
+	return object
+}
+
+// Add a cyber observable with an identifier derived from its value
+func (b *stixBuilder) observable(objectType string, idContributor map[string]any) *stixObject {
+	contributor, _ := json.Marshal(idContributor)
+	object := &stixObject{Type: objectType, SpecVersion: "2.1", ID: objectType + "--" + uuid5(stixNamespace, contributor)}
+	if value, ok := idContributor["value"].(string); ok {
+		object.Value = value
+	}
+	if number, ok := idContributor["number"].(uint32); ok {
+		object.Number = &number
+	}
+	return b.add(object)
+}
+
+// Add a relationship between two objects
+func (b *stixBuilder) relate(source string, relationshipType string, target string, description string) {
+	key, _ := json.Marshal([]string{source, relationshipType, target, description})
+	b.add(&stixObject{
+		Type:             "relationship",
+		SpecVersion:      "2.1",
+		ID:               "relationship--" + uuid5(stixNamespace, key),
+		Created:          b.created,
+		Modified:         b.created,
+		RelationshipType: relationshipType,
+		Description:      description,
+		SourceRef:        source,
+		TargetRef:        target,
+	})
+}
+
+// Add identities for an object's entities and relate them to the object
+func (b *stixBuilder) addEntities(source string, entities []m.Entity) {
+	for _, entity := range entities {
+		name := entityName(entity)
+		if name == "" {
+			name = entity.Handle
+		}
+		if name != "" {
+			identityClass := "organization"
+			if vcardText(entity, "kind") == "individual" {
+				identityClass = "individual"
+			}
+			key, _ := json.Marshal([]string{name, entity.Handle})
+			identity := b.add(&stixObject{
+				Type:               "identity",
+				SpecVersion:        "2.1",
+				ID:                 "identity--" + uuid5(stixNamespace, key),
+				Created:            b.created,
+				Modified:           b.created,
+				Name:               name,
+				IdentityClass:      identityClass,
+				ContactInformation: vcardText(entity, "email"),
+				RdapHandle:         entity.Handle,
+			})
+			for _, role := range entity.Roles {
+				if !slices.Contains(identity.Roles, role) {
+					identity.Roles = append(identity.Roles, role)
+				}
+				b.relate(source, "related-to", identity.ID, "RDAP "+role)
+			}
+		}
+		b.addEntities(source, entity.Entities)
+	}
+}
+
+func (b *stixBuilder) addDomain(result Result) {
+	domain := result.Domain
+	name := strings.ToLower(strings.TrimSuffix(domain.LdhName, "."))
+	if name == "" {
+		name = strings.ToLower(result.Query)
+	}
+
+	object := b.observable("domain-name", map[string]any{"value": name})
+	object.RdapHandle = firstNonEmpty(object.RdapHandle, domain.Handle)
+	object.RdapStatus = mergeStrings(object.RdapStatus, domain.Status)
+	object.RdapEvents = mergeStixEvents(object.RdapEvents, domain.Events)
+	object.RdapSource = firstNonEmpty(object.RdapSource, result.ServerURL)
+	if domain.SecureDNS.DelegationSigned != nil {
+		object.RdapDelegationSigned = domain.SecureDNS.DelegationSigned
+	}
+	if object.RdapqRiskScore == nil {
+		object.RdapqRiskScore = Summarize(result).RiskScore
+	}
+
+	for _, nameserver := range domain.Nameservers {
+		if nameserver.LdhName == "" {
+			continue
+		}
+		nameserverObject := b.observable("domain-name", map[string]any{"value": strings.ToLower(strings.TrimSuffix(nameserver.LdhName, "."))})
+		b.relate(object.ID, "related-to", nameserverObject.ID, "RDAP nameserver")
+	}
+
+	b.addEntities(object.ID, domain.Entities)
+}
+
+func (b *stixBuilder) addIPNetwork(result Result) {
+	network := result.IPNetwork
+
+	addressType := "ipv4-addr"
+	if network.IPVersion == "v6" || strings.Contains(result.Query, ":") {
+		addressType = "ipv6-addr"
+	}
+	value := result.Query
+	if _, err := netip.ParseAddr(value); err != nil {
+		// Not a single address, describe the whole network
+		if cidrs := Summarize(result).NetworkCIDR; len(cidrs) > 0 {
+			value = cidrs[0]
+		}
+	}
+
+	object := b.observable(addressType, map[string]any{"value": value})
+	object.RdapHandle = firstNonEmpty(object.RdapHandle, network.Handle)
+	object.RdapName = firstNonEmpty(object.RdapName, network.Name)
+	if network.StartAddress != "" {
+		object.RdapRange = network.StartAddress + " - " + network.EndAddress
+	}
+	object.RdapStatus = mergeStrings(object.RdapStatus, network.Status)
+	object.RdapEvents = mergeStixEvents(object.RdapEvents, network.Events)
+	object.RdapSource = firstNonEmpty(object.RdapSource, result.ServerURL)
+
+	for _, asn := range originAutnums(*network) {
+		asObject := b.observable("autonomous-system", map[string]any{"number": asn})
+		if !slices.Contains(object.BelongsToRefs, asObject.ID) {
+			object.BelongsToRefs = append(object.BelongsToRefs, asObject.ID)
+		}
+	}
+
+	b.addEntities(object.ID, network.Entities)
+}
+
+func (b *stixBuilder) addAutonum(result Result) {
+	autnum := result.Autonum
+
+	object := b.observable("autonomous-system", map[string]any{"number": queriedASN(result)})
+	object.Name = firstNonEmpty(object.Name, autnum.Name)
+	object.RdapHandle = firstNonEmpty(object.RdapHandle, autnum.Handle)
+	object.RdapStatus = mergeStrings(object.RdapStatus, autnum.Status)
+	object.RdapEvents = mergeStixEvents(object.RdapEvents, autnum.Events)
+	object.RdapSource = firstNonEmpty(object.RdapSource, result.ServerURL)
+
+	b.addEntities(object.ID, autnum.Entities)
+}
+
+// Return the origin ASNs of a network from the arin_originas0 extension
+func originAutnums(network m.IPNetwork) []uint32 {
+	var autnums []uint32
+	if raw, ok := network.Extensions["arin_originas0_originautnums"]; ok {
+		json.Unmarshal(raw, &autnums)
+	}
+	return autnums
+}
+
+func mergeStixEvents(existing []stixEvent, events []m.Events) []stixEvent {
+	for _, event := range events {
+		stixEvent := stixEvent{Action: event.EventAction, Date: event.EventDate, Actor: event.EventActor}
+		if !slices.Contains(existing, stixEvent) {
+			existing = append(existing, stixEvent)
+		}
+	}
+	return existing
+}
+
+func mergeStrings(existing []string, values []string) []string {
+	for _, value := range values {
+		if !slices.Contains(existing, value) {
+			existing = append(existing, value)
+		}
+	}
+	return existing
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Name based UUID (version 5)
+func uuid5(namespace [16]byte, name []byte) string {
+	hash := sha1.New()
+	hash.Write(namespace[:])
+	hash.Write(name)
+	sum := hash.Sum(nil)
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return formatUUID(sum[:16])
+}
+
+// Random UUID (version 4)
+func uuid4() string {
+	var bytes [16]byte
+	rand.Read(bytes[:])
+	bytes[6] = (bytes[6] & 0x0f) | 0x40
+	bytes[8] = (bytes[8] & 0x3f) | 0x80
+	return formatUUID(bytes[:])
+}
+
+func formatUUID(bytes []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:16])
+}
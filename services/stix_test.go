@@ -0,0 +1,85 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// SCO identifier computed from the namespace as the STIX 2.1 specification
+// writes it
+// https://docs.oasis-open.org/cti/stix/v2.1/os/stix-v2.1-os.html#_64yvzeku5a5c
+func stixSCOID(t *testing.T, objectType string, contributor string) string {
+	t.Helper()
+	namespace, err := hex.DecodeString(strings.ReplaceAll("00abedb4-aa42-466c-9c01-fed23315a9b7", "-", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha1.Sum(append(namespace, contributor...))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%v--%x-%x-%x-%x-%x", objectType, sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func TestSTIXBundleDeterministic(t *testing.T) {
+	domain := fixtureResult(t, "example.com", "domain", "verisign_domain.json")
+	domain.FetchedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	network := fixtureResult(t, "192.0.2.1", "ip network", "arin_network.json")
+	results := []Result{domain, network}
+
+	var first, second bytes.Buffer
+	if err := (stixRenderer{}).Render(&first, results); err != nil {
+		t.Fatal(err)
+	}
+	if err := (stixRenderer{}).Render(&second, results); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatalf("bundles differ:\n%s\n%s", first.Bytes(), second.Bytes())
+	}
+
+	var bundle struct {
+		ID      string `json:"id"`
+		Objects []struct {
+			ID      string `json:"id"`
+			Type    string `json:"type"`
+			Value   string `json:"value"`
+			Created string `json:"created"`
+		} `json:"objects"`
+	}
+	if err := json.Unmarshal(first.Bytes(), &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(bundle.ID, "bundle--") {
+		t.Errorf("bundle ID %v", bundle.ID)
+	}
+
+	var IDs []string
+	found := map[string]bool{}
+	for _, object := range bundle.Objects {
+		IDs = append(IDs, object.ID)
+		found[object.ID] = true
+		if object.Created != "" && object.Created != "2026-01-02T03:04:05.000Z" {
+			t.Errorf("%v created %v, want the lookup time", object.ID, object.Created)
+		}
+	}
+	if !slices.IsSorted(IDs) {
+		t.Errorf("objects not sorted by ID: %v", IDs)
+	}
+
+	for _, want := range []string{
+		stixSCOID(t, "domain-name", `{"value":"example.com"}`),
+		stixSCOID(t, "domain-name", `{"value":"a.iana-servers.net"}`),
+		stixSCOID(t, "ipv4-addr", `{"value":"192.0.2.1"}`),
+	} {
+		if !found[want] {
+			t.Errorf("no object %v in %v", want, IDs)
+		}
+	}
+}
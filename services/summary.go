@@ -0,0 +1,238 @@
+package services
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Version of the Summary schema. Bump it whenever a field is added, renamed,
+// removed or changes meaning so downstream parsers can detect the change.
+const SummaryVersion = "4"
+
+// Summary is a flat record of one lookup for CSV, SIEM and spreadsheet use.
+// The schema is documented in the readme; empty strings mean the member was
+// not present in the response. Multi-valued fields are joined with ";" in
+// flat formats.
+type Summary struct {
+	SchemaVersion     string    `json:"schemaVersion"`
+	Query             string    `json:"query"`
+	ObjectType        string    `json:"objectType"`
+	Handle            string    `json:"handle"`
+	Name              string    `json:"name"`
+	Registrar         string    `json:"registrar"`
+	RegistrarIANAID   string    `json:"registrarIanaId"`
+	RegistrantOrg     string    `json:"registrantOrg"`
+	RegistrantCountry string    `json:"registrantCountry"`
+	AbuseEmail        string    `json:"abuseEmail"`
+	Created           string    `json:"created"`
+	Updated           string    `json:"updated"`
+	Expires           string    `json:"expires"`
+	Statuses          []string  `json:"statuses"`
+	Nameservers       []string  `json:"nameservers"`
+	DNSSEC            bool      `json:"dnssec"`
+	NetworkStart      string    `json:"networkStart"`
+	NetworkEnd        string    `json:"networkEnd"`
+	NetworkCIDR       []string  `json:"networkCidr"`
+	Country           string    `json:"country"`
+	SourceServer      string    `json:"sourceServer"`
+	FetchedAt         time.Time `json:"fetchedAt"`
+	AgeDays           *int      `json:"ageDays"`
+	DaysToExpiry      *int      `json:"daysToExpiry"`
+	RiskScore         *int      `json:"riskScore"`
+	RiskReasons       []string  `json:"riskReasons"`
+	Capabilities      []string  `json:"capabilities"`
+}
+
+// Column names of a flat Summary row, in order
+var SummaryFields = []string{
+	"schemaVersion", "query", "objectType", "handle", "name",
+	"registrar", "registrarIanaId", "registrantOrg", "registrantCountry", "abuseEmail",
+	"created", "updated", "expires", "statuses", "nameservers", "dnssec",
+	"networkStart", "networkEnd", "networkCidr", "country", "sourceServer", "fetchedAt",
+	"ageDays", "daysToExpiry", "riskScore", "riskReasons", "capabilities",
+}
+
+// Return the summary as a flat row matching SummaryFields
+func (summary Summary) Row() []string {
+	var fetchedAt string
+	if !summary.FetchedAt.IsZero() {
+		fetchedAt = summary.FetchedAt.Format(time.RFC3339)
+	}
+
+	return []string{
+		summary.SchemaVersion, summary.Query, summary.ObjectType, summary.Handle, summary.Name,
+		summary.Registrar, summary.RegistrarIANAID, summary.RegistrantOrg, summary.RegistrantCountry, summary.AbuseEmail,
+		summary.Created, summary.Updated, summary.Expires,
+		strings.Join(summary.Statuses, ";"), strings.Join(summary.Nameservers, ";"), strconv.FormatBool(summary.DNSSEC),
+		summary.NetworkStart, summary.NetworkEnd, strings.Join(summary.NetworkCIDR, ";"),
+		summary.Country, summary.SourceServer, fetchedAt,
+		intString(summary.AgeDays), intString(summary.DaysToExpiry), intString(summary.RiskScore), strings.Join(summary.RiskReasons, ";"),
+		strings.Join(summary.Capabilities, ";"),
+	}
+}
+
+func intString(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+// Build the summary record of a lookup result
+func Summarize(result Result) Summary {
+	summary := Summary{
+		SchemaVersion: SummaryVersion,
+		Query:         result.Query,
+		SourceServer:  result.ServerURL,
+		FetchedAt:     result.FetchedAt,
+		Capabilities:  result.capabilities(),
+	}
+
+	var entities []m.Entity
+	var events []m.Events
+
+	if domain := result.Domain; domain != nil {
+		summary.ObjectType = "domain"
+		summary.Handle = domain.Handle
+		summary.Name = domain.LdhName
+		summary.Statuses = domain.Status
+		for _, nameserver := range domain.Nameservers {
+			summary.Nameservers = append(summary.Nameservers, strings.ToLower(nameserver.LdhName))
+		}
+		summary.DNSSEC = domain.SecureDNS.DelegationSigned != nil && *domain.SecureDNS.DelegationSigned
+		if domain.Network != nil {
+			summarizeNetwork(&summary, *domain.Network)
+		}
+		risk := ScoreDomain(*domain, time.Now(), RiskConfig)
+		summary.AgeDays, summary.DaysToExpiry = risk.AgeDays, risk.DaysToExpiry
+		summary.RiskScore, summary.RiskReasons = &risk.Score, risk.Reasons
+		entities = domain.Entities
+		events = domain.Events
+	} else if network := result.IPNetwork; network != nil {
+		summary.ObjectType = "ip network"
+		summary.Handle = network.Handle
+		summary.Name = network.Name
+		summary.Statuses = network.Status
+		summarizeNetwork(&summary, *network)
+		entities = network.Entities
+		events = network.Events
+	} else if autnum := result.Autonum; autnum != nil {
+		summary.ObjectType = "autnum"
+		summary.Handle = autnum.Handle
+		summary.Name = autnum.Name
+		summary.Statuses = autnum.Status
+		summary.NetworkStart = "AS" + strconv.FormatUint(uint64(autnum.StartAutnum), 10)
+		summary.NetworkEnd = "AS" + strconv.FormatUint(uint64(autnum.EndAutnum), 10)
+		summary.Country = autnum.Country
+		entities = autnum.Entities
+		events = autnum.Events
+	} else if entity := result.Entity; entity != nil {
+		summary.ObjectType = "entity"
+		summary.Handle = entity.Handle
+		summary.Name = entityName(*entity)
+		summary.Statuses = entity.Status
+		entities = []m.Entity{*entity}
+		events = entity.Events
+	} else if nameserver := result.Nameserver; nameserver != nil {
+		summary.ObjectType = "nameserver"
+		summary.Handle = nameserver.Handle
+		summary.Name = strings.ToLower(nameserver.LdhName)
+		summary.Statuses = nameserver.Status
+		entities = nameserver.Entities
+		events = nameserver.Events
+	}
+
+	if registrar := findEntity(entities, "registrar"); registrar != nil {
+		summary.Registrar = entityName(*registrar)
+		summary.RegistrarIANAID = ianaRegistrarID(*registrar)
+	}
+	if registrant := findEntity(entities, "registrant"); registrant != nil {
+		summary.RegistrantOrg = entityName(*registrant)
+		summary.RegistrantCountry = entityCountry(*registrant)
+	}
+	if abuse := findEntity(entities, "abuse"); abuse != nil {
+		summary.AbuseEmail = vcardText(*abuse, "email")
+	}
+
+	summary.Created = eventDate(events, "registration")
+	summary.Updated = eventDate(events, "last changed")
+	summary.Expires = eventDate(events, "expiration")
+
+	return summary
+}
+
+// Fill in the address range of a network
+func summarizeNetwork(summary *Summary, network m.IPNetwork) {
+	summary.NetworkStart = network.StartAddress
+	summary.NetworkEnd = network.EndAddress
+	summary.Country = network.Country
+
+	for _, cidr := range network.Cidr0Cidrs {
+		prefix := cidr.V4Prefix
+		if prefix == "" {
+			prefix = cidr.V6Prefix
+		}
+		summary.NetworkCIDR = append(summary.NetworkCIDR, prefix+"/"+strconv.Itoa(cidr.Length))
+	}
+	if summary.NetworkCIDR == nil {
+		summary.NetworkCIDR = rangeToCIDRs(network.StartAddress, network.EndAddress)
+	}
+}
+
+// Return the date of the first event with the given action
+func eventDate(events []m.Events, action string) string {
+	for _, event := range events {
+		if event.EventAction == action {
+			return event.EventDate
+		}
+	}
+	return ""
+}
+
+// Return the smallest set of prefixes covering an address range
+func rangeToCIDRs(start string, end string) []string {
+	var cidrs []string
+
+	first, err := netip.ParseAddr(start)
+	if err != nil {
+		return cidrs
+	}
+	last, err := netip.ParseAddr(end)
+	if err != nil || first.BitLen() != last.BitLen() || last.Less(first) {
+		return cidrs
+	}
+
+	for {
+		// Widen the prefix while it stays aligned and inside the range
+		bits := first.BitLen()
+		for bits > 0 {
+			prefix := netip.PrefixFrom(first, bits-1).Masked()
+			if prefix.Addr() != first || lastAddr(prefix).Compare(last) > 0 {
+				break
+			}
+			bits--
+		}
+		prefix := netip.PrefixFrom(first, bits)
+		cidrs = append(cidrs, prefix.String())
+
+		next := lastAddr(prefix).Next()
+		if !next.IsValid() || next.Compare(last) > 0 {
+			return cidrs
+		}
+		first = next
+	}
+}
+
+// Return the last address of a prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	address := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(address)*8; bit++ {
+		address[bit/8] |= 0x80 >> (bit % 8)
+	}
+	last, _ := netip.AddrFromSlice(address)
+	return last
+}
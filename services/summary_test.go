@@ -0,0 +1,130 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestRangeToCIDRs(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		end   string
+		want  []string
+	}{
+		{"aligned IPv4 range", "192.0.2.0", "192.0.2.255", []string{"192.0.2.0/24"}},
+		{"non-aligned IPv4 range", "192.0.2.5", "192.0.2.20", []string{"192.0.2.5/32", "192.0.2.6/31", "192.0.2.8/29", "192.0.2.16/30", "192.0.2.20/32"}},
+		{"IPv6 range", "2001:db8::", "2001:db8:1:ffff:ffff:ffff:ffff:ffff", []string{"2001:db8::/47"}},
+		{"non-aligned IPv6 range", "2001:db8::1", "2001:db8::3", []string{"2001:db8::1/128", "2001:db8::2/127"}},
+		{"single address", "198.51.100.7", "198.51.100.7", []string{"198.51.100.7/32"}},
+		{"whole IPv4 space", "0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"end before start", "192.0.2.9", "192.0.2.1", nil},
+		{"mixed families", "192.0.2.0", "2001:db8::", nil},
+		{"invalid address", "192.0.2", "192.0.2.255", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := rangeToCIDRs(test.start, test.end); !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// Decode a response from the models testdata
+func fixtureResult(t *testing.T, query string, objectClassName string, name string) Result {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "models", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decodedResult(t, query, objectClassName, string(data))
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Result
+		want   Summary
+	}{
+		{
+			name:   "domain",
+			result: fixtureResult(t, "example.com", "domain", "verisign_domain.json"),
+			want: Summary{
+				Query: "example.com", ObjectType: "domain", Handle: "2336799_DOMAIN_COM-VRSN", Name: "EXAMPLE.COM",
+				Registrar: "RESERVED-Internet Assigned Numbers Authority", RegistrarIANAID: "376",
+				Created: "1995-08-14T04:00:00Z", Updated: "2025-08-14T07:01:39Z", Expires: "2026-08-13T04:00:00Z",
+				Statuses:    []string{"client delete prohibited", "client transfer prohibited", "client update prohibited"},
+				Nameservers: []string{"a.iana-servers.net", "b.iana-servers.net"}, DNSSEC: true,
+				Capabilities: []string{"icann_rdap_response_profile", "icann_rdap_technical_implementation_guide"},
+			},
+		},
+		{
+			name:   "ip network",
+			result: fixtureResult(t, "192.0.2.1", "ip network", "arin_network.json"),
+			want: Summary{
+				Query: "192.0.2.1", ObjectType: "ip network", Handle: "NET-192-0-2-0-1", Name: "TEST-NET-1",
+				RegistrantOrg: "Internet Assigned Numbers Authority",
+				Created:       "2009-11-19T11:05:59-05:00", Updated: "2013-08-30T13:15:50-04:00", Statuses: []string{"active"},
+				NetworkStart: "192.0.2.0", NetworkEnd: "192.0.2.255", NetworkCIDR: []string{"192.0.2.0/24"},
+				Capabilities: []string{"cidr0", "arin_originas0", "nro_rdap_profile"},
+			},
+		},
+		{
+			name:   "autnum",
+			result: fixtureResult(t, "AS4608", "autnum", "apnic_autnum.json"),
+			want: Summary{
+				Query: "AS4608", ObjectType: "autnum", Handle: "AS4608", Name: "APNIC-SERVICES",
+				Created: "2008-09-04T06:40:29Z", Updated: "2021-05-03T03:08:32Z", Statuses: []string{"active"},
+				NetworkStart: "AS4608", NetworkEnd: "AS4608", Country: "AU",
+				Capabilities: []string{"cidr0", "nro_rdap_profile"},
+			},
+		},
+		{
+			name: "entity",
+			result: decodedResult(t, "ABUSE-1", "entity", `{"objectClassName": "entity", "handle": "ABUSE-1", "roles": ["abuse"],
+				"vcardArray": ["vcard", [["fn", {}, "text", "Abuse Desk"], ["email", {}, "text", "abuse@example.net"]]],
+				"events": [{"eventAction": "registration", "eventDate": "2001-01-01T00:00:00Z"}]}`),
+			want: Summary{
+				Query: "ABUSE-1", ObjectType: "entity", Handle: "ABUSE-1", Name: "Abuse Desk",
+				AbuseEmail: "abuse@example.net", Created: "2001-01-01T00:00:00Z",
+			},
+		},
+		{
+			name: "nameserver",
+			result: decodedResult(t, "NS1.EXAMPLE.NET", "nameserver", `{"objectClassName": "nameserver", "handle": "NS1-EXAMPLE", "ldhName": "NS1.EXAMPLE.NET",
+				"status": ["active"], "events": [{"eventAction": "last changed", "eventDate": "2020-02-02T00:00:00Z"}]}`),
+			want: Summary{
+				Query: "NS1.EXAMPLE.NET", ObjectType: "nameserver", Handle: "NS1-EXAMPLE", Name: "ns1.example.net",
+				Statuses: []string{"active"}, Updated: "2020-02-02T00:00:00Z",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Summarize(test.result)
+			if got.SchemaVersion != SummaryVersion {
+				t.Errorf("schemaVersion %q", got.SchemaVersion)
+			}
+			if test.want.ObjectType == "domain" && (got.AgeDays == nil || got.DaysToExpiry == nil || got.RiskScore == nil) {
+				t.Errorf("risk facts missing: %+v", got)
+			}
+			if len(got.Row()) != len(SummaryFields) {
+				t.Errorf("%v columns, want %v", len(got.Row()), len(SummaryFields))
+			}
+
+			// The risk facts depend on the current date, risk_test.go covers them
+			got.AgeDays, got.DaysToExpiry, got.RiskScore, got.RiskReasons = nil, nil, nil, nil
+			test.want.SchemaVersion = SummaryVersion
+			test.want.SourceServer = test.result.ServerURL
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got  %+v\nwant %+v", got, test.want)
+			}
+		})
+	}
+}
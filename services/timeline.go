@@ -0,0 +1,117 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// One chronological list of the events in a set of results, from the
+// object itself, its entities, nameservers and DS records
+
+// Event with the object it belongs to and the server that published it
+type TimelineEvent struct {
+	Date   *time.Time `json:"date,omitempty"`
+	Raw    string     `json:"rawDate"`
+	Action string     `json:"action"`
+	Actor  string     `json:"actor,omitempty"`
+	Object string     `json:"object"`
+	Source string     `json:"source"`
+}
+
+// Merge the events of every result, oldest first. Events with dates that do
+// not parse are kept at the end in the order they were found.
+func Timeline(results []Result) []TimelineEvent {
+	timeline := []TimelineEvent{}
+
+	for _, result := range results {
+		add := func(object string, events []m.Events) {
+			for _, event := range events {
+				entry := TimelineEvent{Raw: event.EventDate, Action: event.EventAction, Actor: event.EventActor, Object: object, Source: result.ServerURL}
+				if date, err := event.Date(); err == nil {
+					date = date.UTC()
+					entry.Date = &date
+				}
+				timeline = append(timeline, entry)
+			}
+		}
+
+		var entities []m.Entity
+		if domain := result.Domain; domain != nil {
+			add("domain "+strings.ToLower(firstNonEmpty(domain.LdhName, result.Query)), domain.Events)
+			for _, nameserver := range domain.Nameservers {
+				add("nameserver "+strings.ToLower(nameserver.LdhName), nameserver.Events)
+				entities = append(entities, nameserver.Entities...)
+			}
+			for _, ds := range domain.SecureDNS.DSData {
+				add(fmt.Sprintf("DS %v", ds.KeyTag), ds.Events)
+			}
+			if domain.Network != nil {
+				add("ip network "+domain.Network.Handle, domain.Network.Events)
+			}
+			entities = append(entities, domain.Entities...)
+		} else if network := result.IPNetwork; network != nil {
+			add("ip network "+firstNonEmpty(network.Handle, result.Query), network.Events)
+			entities = network.Entities
+		} else if autnum := result.Autonum; autnum != nil {
+			add("autnum "+firstNonEmpty(autnum.Handle, result.Query), autnum.Events)
+			entities = autnum.Entities
+		}
+		addEntityEvents(add, entities)
+	}
+
+	slices.SortStableFunc(timeline, func(a TimelineEvent, b TimelineEvent) int {
+		switch {
+		case a.Date == nil && b.Date == nil:
+			return 0
+		case a.Date == nil:
+			return 1
+		case b.Date == nil:
+			return -1
+		}
+		return a.Date.Compare(*b.Date)
+	})
+	return timeline
+}
+
+// Add the events of entities and their nested entities
+func addEntityEvents(add func(object string, events []m.Events), entities []m.Entity) {
+	for _, entity := range entities {
+		object := "entity " + firstNonEmpty(entity.Handle, entityName(entity))
+		if len(entity.Roles) > 0 {
+			object += " (" + strings.Join(entity.Roles, ", ") + ")"
+		}
+		add(object, entity.Events)
+		addEntityEvents(add, entity.Entities)
+	}
+}
+
+// Print the timeline as a table
+func PrintTimeline(w io.Writer, timeline []TimelineEvent) {
+	table := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(table, "Date\tAction\tObject\tActor\tSource\n")
+	for _, event := range timeline {
+		date := event.Raw
+		if event.Date != nil {
+			date = event.Date.Format(time.RFC3339)
+		}
+		fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\n", date, event.Action, event.Object, event.Actor, event.Source)
+	}
+	table.Flush()
+}
+
+// Write the timeline as a JSON array
+func WriteTimelineJSON(w io.Writer, timeline []TimelineEvent) error {
+	output, err := json.MarshalIndent(timeline, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(output, '\n'))
+	return err
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// Follow the links between RDAP objects and collect them as a graph
+// https://datatracker.ietf.org/doc/html/rfc9083#section-4.2
+//
+// related: the same object at another server, usually the registrar
+// self:    the canonical URL of an object
+// up:      the parent object, e.g. the network containing a network
+// entity:  the self link of an entity embedded in an object
+//
+// "down" links usually lead to a search of child objects rather than an
+// object, so they are left to -hierarchy.
+
+// Link relations followed by default
+var DefaultTraverseRels = []string{"related", "self", "up", "entity"}
+
+// Traversal options
+type TraverseOptions struct {
+	Rels   []string
+	Depth  int
+	Budget int
+}
+
+// Fetched object. ID is the normalized URL the object was fetched from.
+type GraphNode struct {
+	ID     string
+	Depth  int
+	Result Result
+}
+
+// Link from one object to another
+type GraphEdge struct {
+	From string
+	To   string
+	Rel  string
+}
+
+// Objects reached from a set of lookups and the links between them. Skipped
+// holds the URLs left unfetched once the request budget ran out.
+type Graph struct {
+	Nodes   []*GraphNode
+	Edges   []GraphEdge
+	Skipped []string
+	index   map[string]*GraphNode
+}
+
+// Walk the links of the results breadth first up to options.Depth links
+// away, fetching at most options.Budget objects. URLs already in the graph
+// are linked to rather than fetched again, so cycles end.
+func Traverse(results []Result, options TraverseOptions) *Graph {
+	graph := &Graph{index: map[string]*GraphNode{}}
+	requests := 0
+
+	var queue []*GraphNode
+	for _, result := range results {
+		node, found := graph.add(result, 0)
+		if !found {
+			queue = append(queue, node)
+		}
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if node.Depth >= options.Depth {
+			continue
+		}
+
+		for _, link := range objectLinks(node.Result, options.Rels) {
+			target := normalizeURL(link.Href)
+			if target == node.ID {
+				continue
+			}
+			if _, found := graph.index[target]; found {
+				graph.link(node.ID, target, link.Rel)
+				continue
+			}
+			if requests >= options.Budget {
+				if !slices.Contains(graph.Skipped, target) {
+					graph.Skipped = append(graph.Skipped, target)
+				}
+				continue
+			}
+
+			requests++
+			fmt.Fprintf(Status, "\n(+) Following %v link:\t%v", link.Rel, link.Href)
+			result, err := fetchObject(link.Href)
+			if err != nil {
+				fmt.Fprintf(Status, "\n(!) %v", err)
+				continue
+			}
+			next, found := graph.add(result, node.Depth+1)
+			graph.link(node.ID, next.ID, link.Rel)
+			if !found {
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return graph
+}
+
+// Return the node for a result, adding it when its URL is new. The node is
+// also indexed under the object's self link so a later link to it is not
+// fetched again.
+func (graph *Graph) add(result Result, depth int) (*GraphNode, bool) {
+	ID := normalizeURL(result.ServerURL)
+	if node, found := graph.index[ID]; found {
+		return node, true
+	}
+
+	// Fetched again under another URL
+	self := normalizeURL(findLink(resultLinks(result), "self"))
+	if node, found := graph.index[self]; found && self != "" {
+		graph.index[ID] = node
+		return node, true
+	}
+
+	node := &GraphNode{ID: ID, Depth: depth, Result: result}
+	graph.Nodes = append(graph.Nodes, node)
+	graph.index[ID] = node
+	if self != "" {
+		graph.index[self] = node
+	}
+	return node, false
+}
+
+// Return the results of every node in the order they were reached
+func (graph *Graph) Results() []Result {
+	var results []Result
+	for _, node := range graph.Nodes {
+		results = append(results, node.Result)
+	}
+	return results
+}
+
+// Record an edge once
+func (graph *Graph) link(from string, to string, rel string) {
+	to = graph.index[to].ID
+	edge := GraphEdge{From: from, To: to, Rel: rel}
+	if from != to && !slices.Contains(graph.Edges, edge) {
+		graph.Edges = append(graph.Edges, edge)
+	}
+}
+
+// Links of a result with a followed relation. Entity self links are
+// returned with the "entity" relation.
+func objectLinks(result Result, rels []string) []m.Links {
+	var links []m.Links
+	for _, link := range resultLinks(result) {
+		if slices.Contains(rels, link.Rel) && isRDAPLink(link) {
+			links = append(links, link)
+		}
+	}
+
+	if slices.Contains(rels, "entity") {
+		var addEntities func(entities []m.Entity)
+		addEntities = func(entities []m.Entity) {
+			for _, entity := range entities {
+				if self := findLink(entity.Links, "self"); self != "" {
+					links = append(links, m.Links{Rel: "entity", Href: self, Type: "application/rdap+json"})
+				}
+				addEntities(entity.Entities)
+			}
+		}
+		addEntities(resultEntities(result))
+	}
+	return links
+}
+
+// Skip links to HTML pages and other non RDAP resources
+func isRDAPLink(link m.Links) bool {
+	if link.Href == "" {
+		return false
+	}
+	return link.Type == "" || strings.Contains(link.Type, "rdap+json") || link.Type == "application/json"
+}
+
+func resultLinks(result Result) []m.Links {
+	switch {
+	case result.Domain != nil:
+		return result.Domain.Links
+	case result.IPNetwork != nil:
+		return result.IPNetwork.Links
+	case result.Autonum != nil:
+		return result.Autonum.Links
+	case result.Entity != nil:
+		return result.Entity.Links
+	case result.Nameserver != nil:
+		return result.Nameserver.Links
+	}
+	return nil
+}
+
+func resultEntities(result Result) []m.Entity {
+	switch {
+	case result.Domain != nil:
+		return result.Domain.Entities
+	case result.IPNetwork != nil:
+		return result.IPNetwork.Entities
+	case result.Autonum != nil:
+		return result.Autonum.Entities
+	case result.Entity != nil:
+		return result.Entity.Entities
+	case result.Nameserver != nil:
+		return result.Nameserver.Entities
+	}
+	return nil
+}
+
+// Fetch any RDAP object by URL, decoding it by its objectClassName
+func fetchObject(objectURL string) (Result, error) {
+	body, capabilities, err := queryRDAPServer(objectURL)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var header struct {
+		ObjectClassName string `json:"objectClassName"`
+		LdhName         string `json:"ldhName"`
+		Handle          string `json:"handle"`
+	}
+	if err := json.Unmarshal(body, &header); err != nil {
+		return Result{}, fmt.Errorf("error un-marshalling %v:\n%v", objectURL, err)
+	}
+
+	result := Result{Query: firstNonEmpty(header.LdhName, header.Handle, objectURL), ServerURL: objectURL, FetchedAt: time.Now().UTC(), Raw: body, Capabilities: capabilities}
+	if err := result.decode(header.ObjectClassName); err != nil {
+		return Result{}, fmt.Errorf("%v: %v", objectURL, err)
+	}
+	return result, nil
+}
+
+// Lower-case the scheme and host and drop a trailing slash and fragment so
+// the same object is recognized under small URL variations
+func normalizeURL(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.Fragment = ""
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+	return parsed.String()
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func quietStatus(t *testing.T) {
+	status := Status
+	Status = io.Discard
+	t.Cleanup(func() { Status = status })
+}
+
+// RDAP server answering each path with its object, BASE in an object
+// replaced by the server's URL. Returns the server and the paths requested.
+func rdapServer(t *testing.T, objects map[string]string) (*httptest.Server, func() []string) {
+	t.Helper()
+	quietStatus(t)
+	resetCapabilities(t)
+
+	var mutex sync.Mutex
+	var requested []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requested = append(requested, r.URL.Path)
+		mutex.Unlock()
+
+		object, found := objects[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rdap+json")
+		io.WriteString(w, strings.ReplaceAll(object, "BASE", server.URL))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string(nil), requested...)
+	}
+}
+
+// Three networks linked up in a cycle, the first with a contact. The first
+// network is looked up by address, so it is only known by its self link.
+var traverseObjects = map[string]string{
+	"/ip/192.0.2.1": `{"objectClassName": "ip network", "handle": "A", "links": [
+		{"rel": "self", "href": "BASE/ip/a"},
+		{"rel": "up", "href": "BASE/ip/b"},
+		{"rel": "alternate", "href": "BASE/whois/a", "type": "text/html"}
+	], "entities": [{"objectClassName": "entity", "handle": "E", "roles": ["registrant"], "links": [{"rel": "self", "href": "BASE/entity/E"}]}]}`,
+	"/ip/b":     `{"objectClassName": "ip network", "handle": "B", "links": [{"rel": "self", "href": "BASE/ip/b"}, {"rel": "up", "href": "BASE/ip/c"}]}`,
+	"/ip/c":     `{"objectClassName": "ip network", "handle": "C", "links": [{"rel": "self", "href": "BASE/IP/c/"}, {"rel": "up", "href": "BASE/ip/a#top"}]}`,
+	"/entity/E": `{"objectClassName": "entity", "handle": "E", "links": [{"rel": "self", "href": "BASE/entity/E"}]}`,
+}
+
+func TestTraverse(t *testing.T) {
+	tests := []struct {
+		name      string
+		rels      []string
+		depth     int
+		budget    int
+		nodes     []string
+		edges     []string
+		skipped   []string
+		requested []string
+	}{
+		{
+			name:      "depth limits the links followed",
+			rels:      DefaultTraverseRels,
+			depth:     1,
+			budget:    20,
+			nodes:     []string{"A", "B", "E"},
+			edges:     []string{"A up B", "A entity E"},
+			requested: []string{"/ip/b", "/entity/E"},
+		},
+		{
+			name:      "cycle back to an object known by its self link",
+			rels:      DefaultTraverseRels,
+			depth:     5,
+			budget:    20,
+			nodes:     []string{"A", "B", "E", "C"},
+			edges:     []string{"A up B", "A entity E", "B up C", "C up A"},
+			requested: []string{"/ip/b", "/entity/E", "/ip/c"},
+		},
+		{
+			name:      "budget exhausted",
+			rels:      DefaultTraverseRels,
+			depth:     5,
+			budget:    1,
+			nodes:     []string{"A", "B"},
+			edges:     []string{"A up B"},
+			skipped:   []string{"BASE/entity/E", "BASE/ip/c"},
+			requested: []string{"/ip/b"},
+		},
+		{
+			name:      "only the chosen relations",
+			rels:      []string{"entity"},
+			depth:     5,
+			budget:    20,
+			nodes:     []string{"A", "E"},
+			edges:     []string{"A entity E"},
+			requested: []string{"/entity/E"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, requested := rdapServer(t, traverseObjects)
+			start, err := fetchObject(server.URL + "/ip/192.0.2.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			graph := Traverse([]Result{start}, TraverseOptions{Rels: test.rels, Depth: test.depth, Budget: test.budget})
+
+			handles := map[string]string{}
+			var nodes []string
+			for _, node := range graph.Nodes {
+				handle := Summarize(node.Result).Handle
+				handles[node.ID] = handle
+				nodes = append(nodes, handle)
+			}
+			var edges []string
+			for _, edge := range graph.Edges {
+				edges = append(edges, handles[edge.From]+" "+edge.Rel+" "+handles[edge.To])
+			}
+			var skipped []string
+			for _, URL := range graph.Skipped {
+				skipped = append(skipped, strings.ReplaceAll(URL, server.URL, "BASE"))
+			}
+
+			if !reflect.DeepEqual(nodes, test.nodes) {
+				t.Errorf("nodes %q, want %q", nodes, test.nodes)
+			}
+			if !reflect.DeepEqual(edges, test.edges) {
+				t.Errorf("edges %q, want %q", edges, test.edges)
+			}
+			if !reflect.DeepEqual(skipped, test.skipped) {
+				t.Errorf("skipped %q, want %q", skipped, test.skipped)
+			}
+			// Every object is fetched once, the starting lookup included
+			if got := requested()[1:]; !reflect.DeepEqual(got, test.requested) {
+				t.Errorf("requested %q, want %q", got, test.requested)
+			}
+		})
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := map[string]string{
+		"HTTPS://RDAP.Example/ip/192.0.2.0/": "https://rdap.example/ip/192.0.2.0",
+		"https://rdap.example/ip/a#top":      "https://rdap.example/ip/a",
+		" https://rdap.example/Entity/E ":    "https://rdap.example/Entity/E",
+		"not a url":                          "not a url",
+	}
+	for rawURL, want := range tests {
+		if got := normalizeURL(rawURL); got != want {
+			t.Errorf("normalizeURL(%q) = %q, want %q", rawURL, got, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+
+	m "github.com/kadonnelly13/rdapq/models"
+)
+
+// jCard helpers
+// https://datatracker.ietf.org/doc/html/rfc7095
+
+// vCard property from an entity's jCard
+type vcardProperty struct {
+	Parameters map[string]interface{}
+	Value      interface{}
+}
+
+// Return every property with the given name from an entity's jCard
+func vcardProperties(entity m.Entity, name string) []vcardProperty {
+	var properties []vcardProperty
+
+	if len(entity.VcardArray) < 2 {
+		return properties
+	}
+	card, ok := entity.VcardArray[1].([]interface{})
+	if !ok {
+		return properties
+	}
+
+	for _, item := range card {
+		// [name, parameters, type, value...]
+		property, ok := item.([]interface{})
+		if !ok || len(property) < 4 {
+			continue
+		}
+		if propertyName, _ := property[0].(string); !strings.EqualFold(propertyName, name) {
+			continue
+		}
+		parameters, _ := property[1].(map[string]interface{})
+		properties = append(properties, vcardProperty{Parameters: parameters, Value: property[3]})
+	}
+	return properties
+}
+
+// Return the first non-empty text value of a jCard property, or of the
+// matching JSContact property when the server answered with JSContact
+func vcardText(entity m.Entity, name string) string {
+	for _, property := range vcardProperties(entity, name) {
+		switch value := property.Value.(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case []interface{}:
+			// Structured values such as org units
+			for _, part := range value {
+				if text, ok := part.(string); ok && text != "" {
+					return text
+				}
+			}
+		}
+	}
+	return jscardText(entity, name)
+}
+
+// JSContact card of an entity, sent in place of jCard when requested
+// https://datatracker.ietf.org/doc/html/rfc9553
+// https://datatracker.ietf.org/doc/draft-ietf-regext-rdap-jscontact/
+type jscard struct {
+	Kind string `json:"kind"`
+	Name struct {
+		Full string `json:"full"`
+	} `json:"name"`
+	Organizations map[string]jscardEntry `json:"organizations"`
+	Emails        map[string]jscardEntry `json:"emails"`
+	Phones        map[string]jscardEntry `json:"phones"`
+	Addresses     map[string]jscardEntry `json:"addresses"`
+}
+
+// Entry of a JSContact map, with the member read from each kind of entry
+type jscardEntry struct {
+	Name        string `json:"name"`
+	Address     string `json:"address"`
+	Number      string `json:"number"`
+	CountryCode string `json:"countryCode"`
+}
+
+// Return the JSContact value standing in for a jCard property
+func jscardText(entity m.Entity, name string) string {
+	raw, found := entity.Extensions["jscard"]
+	if !found {
+		return ""
+	}
+	var card jscard
+	json.Unmarshal(raw, &card)
+
+	switch name {
+	case "kind":
+		return card.Kind
+	case "fn":
+		return card.Name.Full
+	case "org":
+		return firstEntry(card.Organizations, func(entry jscardEntry) string { return entry.Name })
+	case "email":
+		return firstEntry(card.Emails, func(entry jscardEntry) string { return entry.Address })
+	case "tel":
+		return firstEntry(card.Phones, func(entry jscardEntry) string { return entry.Number })
+	case "adr":
+		return firstEntry(card.Addresses, func(entry jscardEntry) string { return entry.CountryCode })
+	}
+	return ""
+}
+
+// First non-empty value of a JSContact map, in key order so it is stable
+func firstEntry(entries map[string]jscardEntry, value func(jscardEntry) string) string {
+	for _, key := range slices.Sorted(maps.Keys(entries)) {
+		if text := value(entries[key]); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// Return an entity's display name, preferring the organization
+func entityName(entity m.Entity) string {
+	if org := vcardText(entity, "org"); org != "" {
+		return org
+	}
+	return vcardText(entity, "fn")
+}
+
+// Return the country of an entity's address, from the "cc" parameter
+// (RFC 8605) or the country name component of the structured address
+func entityCountry(entity m.Entity) string {
+	for _, property := range vcardProperties(entity, "adr") {
+		if cc, ok := property.Parameters["cc"].(string); ok && cc != "" {
+			return cc
+		}
+		if address, ok := property.Value.([]interface{}); ok && len(address) == 7 {
+			if country, ok := address[6].(string); ok && country != "" {
+				return country
+			}
+		}
+	}
+	return jscardText(entity, "adr")
+}
+
+// Return the first entity holding a role, searching nested entities depth first
+func findEntity(entities []m.Entity, role string) *m.Entity {
+	for i := range entities {
+		for _, entityRole := range entities[i].Roles {
+			if entityRole == role {
+				return &entities[i]
+			}
+		}
+		if entity := findEntity(entities[i].Entities, role); entity != nil {
+			return entity
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Re-query indicators on an interval and alert when watched fields change
+
+// Fields alerted on by default, matched as prefixes of Change.Field
+var DefaultWatchFields = []string{"response", "nameservers", "status", "entities.registrar", "secureDNS"}
+
+// Watch options
+type WatchOptions struct {
+	Interval        time.Duration
+	Fields          []string
+	Format          string
+	Webhook         string
+	HistoryLocation string
+	Once            bool
+}
+
+// Watched changes to one object
+type Alert struct {
+	Time    time.Time `json:"time"`
+	Query   string    `json:"query"`
+	Server  string    `json:"server"`
+	Text    string    `json:"text"`
+	Error   string    `json:"error,omitempty"`
+	Changes []Change  `json:"changes"`
+}
+
+// Shortest -interval accepted, to stay within registry rate limits
+const MinimumWatchInterval = time.Minute
+
+// Webhooks that stop answering must not stall the watch loop
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// Query every indicator each interval, comparing its responses with those of
+// the previous lookup. With a history database the previous lookup
+// survives restarts, so -once can be run from cron.
+func Watch(indicators []string, registryBaseURL string, options WatchOptions) {
+	previous := map[string][]Result{}
+
+	var history *History
+	if options.HistoryLocation != "" {
+		var err error
+		history, err = OpenHistory(options.HistoryLocation)
+		if err != nil {
+			fmt.Fprintf(Status, "\n(!) %v\n", err)
+			os.Exit(1)
+		}
+		defer history.Close()
+	}
+
+	for {
+		for _, indicator := range indicators {
+			fmt.Fprintf(Status, "\n(+) Querying RDAP Service for:\t%v", indicator)
+			results, err := LookupIndicator(indicator, registryBaseURL)
+			if err != nil {
+				fmt.Fprintf(Status, "\n(!) Lookup failed for %v: %v", indicator, err)
+				emitAlert(Alert{
+					Time:    time.Now().UTC(),
+					Query:   indicator,
+					Text:    fmt.Sprintf("rdapq: RDAP lookup failed for %v: %v", indicator, err),
+					Error:   err.Error(),
+					Changes: []Change{},
+				}, options)
+				continue
+			}
+
+			last, found := previous[indicator]
+			if !found && history != nil {
+				last, found = latestSnapshots(history, indicator)
+			}
+
+			if found {
+				// Responses are paired so a registrar response that appears or
+				// disappears is reported as a "response" change
+				for _, pair := range PairResults(last, results) {
+					if changes := watchedChanges(DiffResults(pair.Old, pair.New), options.Fields); len(changes) > 0 {
+						result := pair.New
+						if result.object() == nil {
+							result = pair.Old
+						}
+						emitAlert(newAlert(result, changes), options)
+					}
+				}
+			} else {
+				for _, result := range results {
+					fmt.Fprintf(Status, "\n(+) Baseline recorded for %v from %v", result.Query, result.ServerURL)
+				}
+			}
+			previous[indicator] = results
+
+			if history != nil {
+				if err := history.Save(results); err != nil {
+					fmt.Fprintf(Status, "\n(!) %v", err)
+				}
+			}
+		}
+
+		if options.Once {
+			fmt.Fprintf(Status, "\n")
+			return
+		}
+		fmt.Fprintf(Status, "\n(+) Next check at %v\n", time.Now().Add(options.Interval).Format(time.RFC3339))
+		time.Sleep(options.Interval)
+	}
+}
+
+// Return the stored responses of the most recent lookup of an indicator: the
+// latest snapshot from each server fetched within MinimumWatchInterval of the
+// newest one. Watches are never closer together than that, so older snapshots
+// belong to an earlier lookup.
+func latestSnapshots(history *History, query string) ([]Result, bool) {
+	snapshots, err := history.Snapshots(query)
+	if err != nil || len(snapshots) == 0 {
+		return nil, false
+	}
+
+	newest := snapshots[len(snapshots)-1].FetchedAt
+	var results []Result
+	var servers []string
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if newest.Sub(snapshots[i].FetchedAt) > MinimumWatchInterval {
+			break
+		}
+		if slices.Contains(servers, snapshots[i].Server) {
+			continue
+		}
+		servers = append(servers, snapshots[i].Server)
+
+		snapshot, err := history.Snapshot(snapshots[i].ID)
+		if err != nil {
+			return nil, false
+		}
+		result, err := snapshot.Result()
+		if err != nil {
+			return nil, false
+		}
+		results = append([]Result{result}, results...)
+	}
+	return results, true
+}
+
+// Keep the changes to watched fields
+func watchedChanges(changes []Change, fields []string) []Change {
+	var watched []Change
+	for _, change := range changes {
+		for _, field := range fields {
+			if strings.HasPrefix(change.Field, field) {
+				watched = append(watched, change)
+				break
+			}
+		}
+	}
+	return watched
+}
+
+func newAlert(result Result, changes []Change) Alert {
+	var descriptions []string
+	for _, change := range changes {
+		switch change.Change {
+		case "added":
+			descriptions = append(descriptions, fmt.Sprintf("%v added %v", change.Field, change.New))
+		case "removed":
+			descriptions = append(descriptions, fmt.Sprintf("%v removed %v", change.Field, change.Old))
+		default:
+			descriptions = append(descriptions, fmt.Sprintf("%v changed %v -> %v", change.Field, change.Old, change.New))
+		}
+	}
+
+	return Alert{
+		Time:    time.Now().UTC(),
+		Query:   result.Query,
+		Server:  result.ServerURL,
+		Text:    fmt.Sprintf("rdapq: RDAP change for %v: %v", result.Query, strings.Join(descriptions, "; ")),
+		Changes: changes,
+	}
+}
+
+// Write an alert to stdout and post it to the webhook. The "text" member lets
+// Slack and Teams style incoming webhooks display it as is.
+func emitAlert(alert Alert, options WatchOptions) {
+	alertJSON, _ := json.Marshal(alert)
+
+	if options.Format == "ndjson" {
+		fmt.Printf("%s\n", alertJSON)
+	} else {
+		fmt.Printf("\n(!) ALERT %v\t%v", alert.Time.Format(time.RFC3339), alert.Query)
+		if alert.Error != "" {
+			fmt.Printf("\n\tLookup failed:\t%v", alert.Error)
+		} else {
+			fmt.Printf("\n\tServer:\t%v", alert.Server)
+		}
+		printChangeLines(os.Stdout, alert.Changes)
+		fmt.Printf("\n")
+	}
+
+	if options.Webhook != "" {
+		response, err := webhookClient.Post(options.Webhook, "application/json", bytes.NewReader(alertJSON))
+		if err != nil {
+			fmt.Fprintf(Status, "\n(!) Error posting alert to webhook:\n%v", err)
+			return
+		}
+		response.Body.Close()
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			fmt.Fprintf(Status, "\n(!) Webhook returned %v", response.Status)
+		}
+	}
+}
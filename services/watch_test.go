@@ -0,0 +1,57 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The previous lookup is the latest response from each server fetched
+// together, so a registrar response missing from it is reported
+func TestLatestSnapshots(t *testing.T) {
+	history, err := OpenHistory(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	lookup := func(fetchedAt time.Time, servers ...string) []Result {
+		var results []Result
+		for _, server := range servers {
+			result := decodedResult(t, "example.com", "domain", diffOldDomain)
+			result.ServerURL, result.FetchedAt = server, fetchedAt
+			results = append(results, result)
+		}
+		return results
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, results := range [][]Result{
+		lookup(start, "https://registry.example/domain/example.com", "https://old-registrar.example/domain/example.com"),
+		lookup(start.Add(time.Hour), "https://registry.example/domain/example.com"),
+		lookup(start.Add(time.Hour+time.Second), "https://registrar.example/domain/example.com"),
+	} {
+		if err := history.Save(results); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last, found := latestSnapshots(history, "example.com")
+	if !found || len(last) != 2 ||
+		last[0].ServerURL != "https://registry.example/domain/example.com" ||
+		last[1].ServerURL != "https://registrar.example/domain/example.com" {
+		t.Fatalf("unexpected previous lookup: %v %+v", found, last)
+	}
+	if _, found := latestSnapshots(history, "example.net"); found {
+		t.Error("found a previous lookup of an indicator never saved")
+	}
+
+	current := lookup(start.Add(2*time.Hour), "https://registry.example/domain/example.com")
+	var changes []Change
+	for _, pair := range PairResults(last, current) {
+		changes = append(changes, watchedChanges(DiffResults(pair.Old, pair.New), DefaultWatchFields)...)
+	}
+	if len(changes) != 1 || changes[0].Field != "response" || changes[0].Change != "removed" ||
+		changes[0].Old != "https://registrar.example/domain/example.com" {
+		t.Errorf("unexpected changes: %+v", changes)
+	}
+}